@@ -0,0 +1,191 @@
+// Command csmt-bench generates random Plasma blocks, applies them to a compact sparse Merkle
+// tree and reports per-block timings and sizes as JSON.
+//
+//	csmt-bench -height 48 -block-bits 24 -blocks 5 -outputs 100000 -spend 0.5 -hasher keccak256
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+type config struct {
+	Height      uint8   `json:"height"`
+	BlockBits   uint8   `json:"block_bits"`
+	Blocks      int     `json:"blocks"`
+	Outputs     int     `json:"outputs_per_block"`
+	SpendRatio  float64 `json:"spend_ratio"`
+	Hasher      string  `json:"hasher"`
+	Seed        int64   `json:"seed"`
+	ValueLength int     `json:"value_length"`
+}
+
+type blockReport struct {
+	Block          uint64  `json:"block"`
+	Inserted       int     `json:"inserted"`
+	Deleted        int     `json:"deleted"`
+	ApplyMs        float64 `json:"apply_ms"`
+	AuditNodes     int     `json:"audit_nodes"`
+	AuditBytes     int     `json:"audit_bytes"`
+	ProofUpdateUs  float64 `json:"proof_update_us"`
+	ProofUpdateOK  bool    `json:"proof_update_ok"`
+	CacheEntries   int     `json:"cache_entries"`
+	OutputsPerSec  float64 `json:"outputs_per_sec"`
+	TrackedOutput  uint64  `json:"tracked_output"`
+	TrackedSkipped bool    `json:"tracked_skipped,omitempty"`
+}
+
+type report struct {
+	Config config        `json:"config"`
+	Blocks []blockReport `json:"blocks"`
+}
+
+func main() {
+	var cfg config
+	var height, blockBits uint
+	flag.UintVar(&height, "height", 48, "tree height")
+	flag.UintVar(&blockBits, "block-bits", 24, "number of top index bits used for the block number")
+	flag.IntVar(&cfg.Blocks, "blocks", 3, "number of blocks to produce")
+	flag.IntVar(&cfg.Outputs, "outputs", 10000, "new outputs per block")
+	flag.Float64Var(&cfg.SpendRatio, "spend", 0.5, "spent inputs per block as a fraction of new outputs")
+	flag.StringVar(&cfg.Hasher, "hasher", "sha512_256", "hasher: sha512_256 or keccak256")
+	flag.Int64Var(&cfg.Seed, "seed", 1, "random seed")
+	flag.IntVar(&cfg.ValueLength, "value-length", 32+64, "leaf value length in bytes (amount + pub key)")
+	flag.Parse()
+	cfg.Height = uint8(height)
+	cfg.BlockBits = uint8(blockBits)
+
+	r, err := run(cfg, os.Stderr)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func run(cfg config, logOutput io.Writer) (*report, error) {
+	if cfg.Height == 0 || cfg.Height > 63 || cfg.BlockBits == 0 || cfg.BlockBits >= cfg.Height {
+		return nil, errors.New("block bits must be within the tree height")
+	}
+	if uint64(cfg.Blocks) >= uint64(1)<<cfg.BlockBits {
+		return nil, errors.New("too many blocks for the block prefix")
+	}
+	outputBits := cfg.Height - cfg.BlockBits
+	if outputBits < 63 && uint64(cfg.Outputs) > uint64(1)<<outputBits/2 {
+		return nil, errors.New("too many outputs per block for the output prefix")
+	}
+	hasher, err := csmt.HasherByName(cfg.Hasher)
+	if err != nil {
+		return nil, err
+	}
+	// proof updates log every step, keep it out of the report
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(logOutput)
+
+	rnd := rand.New(rand.NewSource(cfg.Seed))
+	tree := csmt.NewCSMTWithHasher(cfg.Height, false, hasher)
+	var liveIndexes []uint64
+	var tracked *csmt.InsertedIndex
+	var trackedProof csmt.AuditNodes
+
+	r := &report{Config: cfg}
+	for b := 1; b <= cfg.Blocks; b++ {
+		block := uint64(b)
+		prefix := block << outputBits
+
+		spends := int(float64(cfg.Outputs) * cfg.SpendRatio)
+		deletes := make(csmt.DeletionIndexes, 0, spends)
+		for len(deletes) < spends && len(liveIndexes) > 1 {
+			i := rnd.Intn(len(liveIndexes))
+			index := liveIndexes[i]
+			if tracked != nil && index == tracked.Index {
+				continue
+			}
+			deletes = append(deletes, index)
+			liveIndexes[i] = liveIndexes[len(liveIndexes)-1]
+			liveIndexes = liveIndexes[:len(liveIndexes)-1]
+		}
+		sort.Sort(deletes)
+
+		inserts := make(csmt.InsertionIndexes, 0, cfg.Outputs)
+		used := make(map[uint64]bool, cfg.Outputs)
+		for len(inserts) < cfg.Outputs {
+			index := prefix + uint64(rnd.Int63n(int64(uint64(1)<<outputBits)))
+			if used[index] {
+				continue
+			}
+			used[index] = true
+			value := make([]byte, cfg.ValueLength)
+			rnd.Read(value)
+			inserts = append(inserts, csmt.InsertedIndex{Index: index, Value: value})
+		}
+		sort.Sort(inserts)
+
+		now := time.Now()
+		var deleted csmt.AuditNodes
+		if len(deletes) != 0 {
+			deleted = tree.ApplyDeletes(deletes)
+		}
+		inserted := tree.ApplyInserts(inserts)
+		elapsed := time.Since(now)
+		audit := csmt.MergeAuditNodes(deleted, inserted)
+
+		br := blockReport{
+			Block:        block,
+			Inserted:     len(inserts),
+			Deleted:      len(deletes),
+			ApplyMs:      float64(elapsed.Nanoseconds()) / 1e6,
+			AuditNodes:   len(audit),
+			AuditBytes:   len(audit.Encode()),
+			CacheEntries: tree.CacheEntries(),
+		}
+		if elapsed > 0 {
+			br.OutputsPerSec = float64(len(inserts)+len(deletes)) / elapsed.Seconds()
+		}
+		if tracked != nil {
+			br.TrackedOutput = tracked.Index
+			now = time.Now()
+			updated, err := trackedProof.UpdateProofImproved(tracked.Index, audit)
+			br.ProofUpdateUs = float64(time.Since(now).Nanoseconds()) / 1e3
+			if err == nil {
+				err = updated.VerifyPathWith(hasher, cfg.Height, tracked.Index, tracked.Value, tree.RootHash())
+			}
+			br.ProofUpdateOK = err == nil
+			if err == nil {
+				trackedProof = updated
+			} else {
+				trackedProof = tree.Prove(tracked.Index)
+			}
+		} else {
+			br.TrackedSkipped = true
+		}
+
+		for _, leaf := range inserts {
+			liveIndexes = append(liveIndexes, leaf.Index)
+		}
+		if tracked == nil {
+			leaf := inserts[rnd.Intn(len(inserts))]
+			tracked = &leaf
+			trackedProof = inserted.FilterPath(cfg.Height, leaf.Index)
+			if !bytes.Equal(trackedProof[0].Value, tree.RootHash()) {
+				return nil, fmt.Errorf("block %v: filtered proof does not match the root", block)
+			}
+		}
+		r.Blocks = append(r.Blocks, br)
+	}
+	return r, nil
+}
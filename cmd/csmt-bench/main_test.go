@@ -0,0 +1,38 @@
+package main
+
+import (
+	"io"
+	"testing"
+)
+
+func TestBenchRun(t *testing.T) {
+	cfg := config{
+		Height:      20,
+		BlockBits:   8,
+		Blocks:      3,
+		Outputs:     200,
+		SpendRatio:  0.5,
+		Hasher:      "keccak256",
+		Seed:        1,
+		ValueLength: 96,
+	}
+	r, err := run(cfg, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Blocks) != 3 {
+		t.Fatal("Unexpected number of blocks in the report")
+	}
+	for _, b := range r.Blocks[1:] {
+		if !b.ProofUpdateOK {
+			t.Fatalf("Proof update failed in block %v", b.Block)
+		}
+		if b.AuditBytes == 0 || b.CacheEntries == 0 {
+			t.Fatal("Empty sizes in the report")
+		}
+	}
+	cfg.Hasher = "md5"
+	if _, err := run(cfg, io.Discard); err == nil {
+		t.Fatal("Unknown hasher was accepted")
+	}
+}
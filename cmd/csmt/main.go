@@ -0,0 +1,488 @@
+// Command csmt inspects and changes a compact sparse Merkle tree persisted as a snapshot, and
+// checks proofs and audit sets in the JSON format of the library.
+//
+//	csmt [-tree tree.csmt] init [-height 48] [-hasher sha512_256] [-leaves=true]
+//	csmt [-tree tree.csmt] insert <batch>      prints the audit set of the batch
+//	csmt [-tree tree.csmt] delete <batch>      prints the audit set of the batch
+//	csmt [-tree tree.csmt] root
+//	csmt [-tree tree.csmt] prove <index>
+//	csmt [-tree tree.csmt] stats
+//	csmt verify [-hasher sha512_256] [-value 0x..] <proof.json> <root>
+//	csmt update-proof <proof.json> <audit.json>
+//	csmt filter [-height 48] <audit.json> <index>
+//	csmt vectors [-seed 1] [-height 48] [-leaves 64] [-count 8]
+//
+// A batch is JSON, [{"index": 5, "value": "0x.."}, ...], or CSV with index,value lines.
+// Deletions only use the indexes, a JSON batch of deletions can also be a list of numbers.
+// Files can be "-" for the standard input.
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+	"github.com/matterinc/PlasmaCompact/onchain"
+)
+
+const usage = `usage: csmt [-tree file] <command> [arguments]
+
+commands:
+  init [-height 48] [-hasher sha512_256] [-leaves=true]   create an empty tree
+  insert <batch>                 insert leaves, prints the audit set
+  delete <batch>                 delete leaves, prints the audit set
+  root                           print the root
+  prove <index>                  print the proof of a leaf
+  stats                          print the tree parameters and sizes
+  verify [-hasher name] [-value 0x..] <proof.json> <root>
+                                 check a proof, against the value if given
+  update-proof <proof.json> <audit.json>
+                                 bring a proof up to date with an audit set
+  filter [-height 48] <audit.json> <index>
+                                 extract the proof of a leaf from an audit set
+  vectors [-seed 1] [-height 48] [-leaves 64] [-count 8]
+                                 print test vectors of the on-chain verifier
+`
+
+func main() {
+	// proof updates log every step
+	log.SetOutput(io.Discard)
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+type command func(treePath string, args []string, stdin io.Reader, stdout io.Writer) error
+
+var commands = map[string]command{
+	"init":         initTree,
+	"insert":       insert,
+	"delete":       remove,
+	"root":         root,
+	"prove":        prove,
+	"stats":        stats,
+	"verify":       verify,
+	"update-proof": updateProof,
+	"filter":       filter,
+	"vectors":      vectors,
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("csmt", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	treePath := flags.String("tree", "tree.csmt", "tree snapshot file")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return errors.New(usage)
+	}
+	cmd, exists := commands[flags.Arg(0)]
+	if !exists {
+		return fmt.Errorf("unknown command %v\n%v", flags.Arg(0), usage)
+	}
+	return cmd(*treePath, flags.Args()[1:], stdin, stdout)
+}
+
+// parseArgs parses the flags of a command and checks the number of positional arguments.
+func parseArgs(flags *flag.FlagSet, args []string, positional int) error {
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != positional {
+		return fmt.Errorf("%v expects %v arguments\n%v", flags.Name(), positional, usage)
+	}
+	return nil
+}
+
+func loadTree(path string) (*csmt.CSMT, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return csmt.ImportSnapshot(f)
+}
+
+// saveTree replaces the snapshot atomically, a failed write leaves the old one in place.
+func saveTree(path string, tree *csmt.CSMT) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tree.ExportSnapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func readInput(path string, stdin io.Reader) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func readAuditNodes(path string, stdin io.Reader) (csmt.AuditNodes, error) {
+	data, err := readInput(path, stdin)
+	if err != nil {
+		return nil, err
+	}
+	var nodes csmt.AuditNodes
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return nodes, nil
+}
+
+func writeJSON(stdout io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+type batchEntry struct {
+	Index uint64 `json:"index"`
+	Value string `json:"value"`
+}
+
+// readBatch reads a JSON or CSV batch, values are only required for insertions.
+func readBatch(path string, stdin io.Reader, withValues bool) (csmt.InsertionIndexes, error) {
+	data, err := readInput(path, stdin)
+	if err != nil {
+		return nil, err
+	}
+	var entries []batchEntry
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) != 0 && trimmed[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, err
+		}
+		entries = make([]batchEntry, len(items))
+		for i, item := range items {
+			if err := json.Unmarshal(item, &entries[i].Index); err == nil && !withValues {
+				continue
+			}
+			if err := json.Unmarshal(item, &entries[i]); err != nil {
+				return nil, fmt.Errorf("batch entry %v: %v", i, err)
+			}
+		}
+	} else {
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		reader.Comment = '#'
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		for i, record := range records {
+			index, err := strconv.ParseUint(strings.TrimSpace(record[0]), 10, 64)
+			if err != nil {
+				if i == 0 {
+					// header line
+					continue
+				}
+				return nil, fmt.Errorf("batch line %v: %v", i+1, err)
+			}
+			entry := batchEntry{Index: index}
+			if len(record) > 1 {
+				entry.Value = strings.TrimSpace(record[1])
+			}
+			entries = append(entries, entry)
+		}
+	}
+	batch := make(csmt.InsertionIndexes, len(entries))
+	for i, entry := range entries {
+		batch[i].Index = entry.Index
+		if !withValues {
+			continue
+		}
+		if batch[i].Value, err = csmt.DecodeHex(entry.Value); err != nil || len(batch[i].Value) == 0 {
+			return nil, fmt.Errorf("invalid value of leaf %v", entry.Index)
+		}
+	}
+	sort.Sort(batch)
+	for i := 1; i < len(batch); i++ {
+		if batch[i].Index == batch[i-1].Index {
+			return nil, fmt.Errorf("leaf %v is in the batch twice", batch[i].Index)
+		}
+	}
+	return batch, nil
+}
+
+func checkIndex(tree *csmt.CSMT, index uint64) error {
+	if tree.Height < 64 && index >= 1<<tree.Height {
+		return fmt.Errorf("index %v is out of the tree", index)
+	}
+	return nil
+}
+
+func initTree(treePath string, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	height := flags.Uint("height", 48, "tree height")
+	hasherName := flags.String("hasher", "sha512_256", "hasher: sha512_256 or keccak256")
+	leaves := flags.Bool("leaves", true, "store leaf values")
+	if err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+	if *height == 0 || *height > 63 {
+		return errors.New("tree height must be from 1 to 63")
+	}
+	hasher, err := csmt.HasherByName(*hasherName)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(treePath); err == nil {
+		return fmt.Errorf("%v already exists", treePath)
+	}
+	return saveTree(treePath, csmt.NewCSMTWithHasher(uint8(*height), *leaves, hasher))
+}
+
+func insert(treePath string, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("insert", flag.ContinueOnError)
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+	tree, err := loadTree(treePath)
+	if err != nil {
+		return err
+	}
+	batch, err := readBatch(flags.Arg(0), stdin, true)
+	if err != nil {
+		return err
+	}
+	for _, leaf := range batch {
+		if err := checkIndex(tree, leaf.Index); err != nil {
+			return err
+		}
+		if tree.SubtreeRoot(0, leaf.Index) != nil {
+			return fmt.Errorf("leaf %v is not empty", leaf.Index)
+		}
+	}
+	audit := tree.ApplyInserts(batch)
+	if err := saveTree(treePath, tree); err != nil {
+		return err
+	}
+	return writeJSON(stdout, audit)
+}
+
+func remove(treePath string, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+	tree, err := loadTree(treePath)
+	if err != nil {
+		return err
+	}
+	batch, err := readBatch(flags.Arg(0), stdin, false)
+	if err != nil {
+		return err
+	}
+	deletions := make(csmt.DeletionIndexes, len(batch))
+	for i, leaf := range batch {
+		if err := checkIndex(tree, leaf.Index); err != nil {
+			return err
+		}
+		if tree.SubtreeRoot(0, leaf.Index) == nil {
+			return fmt.Errorf("leaf %v is empty", leaf.Index)
+		}
+		deletions[i] = leaf.Index
+	}
+	audit := tree.ApplyDeletes(deletions)
+	if err := saveTree(treePath, tree); err != nil {
+		return err
+	}
+	return writeJSON(stdout, audit)
+}
+
+func root(treePath string, args []string, stdin io.Reader, stdout io.Writer) error {
+	if err := parseArgs(flag.NewFlagSet("root", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	tree, err := loadTree(treePath)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, csmt.EncodeHex(tree.RootHash()))
+	return err
+}
+
+func prove(treePath string, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("prove", flag.ContinueOnError)
+	if err := parseArgs(flags, args, 1); err != nil {
+		return err
+	}
+	index, err := strconv.ParseUint(flags.Arg(0), 10, 64)
+	if err != nil {
+		return err
+	}
+	tree, err := loadTree(treePath)
+	if err != nil {
+		return err
+	}
+	if err := checkIndex(tree, index); err != nil {
+		return err
+	}
+	return writeJSON(stdout, tree.Prove(index))
+}
+
+type treeStats struct {
+	Height       uint8  `json:"height"`
+	Hasher       string `json:"hasher"`
+	Root         string `json:"root"`
+	Leaves       int    `json:"leaves"`
+	CacheEntries int    `json:"cache_entries"`
+	SnapshotSize int64  `json:"snapshot_bytes"`
+}
+
+func stats(treePath string, args []string, stdin io.Reader, stdout io.Writer) error {
+	if err := parseArgs(flag.NewFlagSet("stats", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	tree, err := loadTree(treePath)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(treePath)
+	if err != nil {
+		return err
+	}
+	s := treeStats{tree.Height, tree.Hasher().Name(), csmt.EncodeHex(tree.RootHash()), 0, tree.CacheEntries(), info.Size()}
+	end := uint64(1) << tree.Height
+	if end == 0 {
+		end = ^uint64(0)
+	}
+	for it := tree.Iterate(0, end); it.Next(); {
+		s.Leaves++
+	}
+	return writeJSON(stdout, s)
+}
+
+func verify(treePath string, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	hasherName := flags.String("hasher", "sha512_256", "hasher: sha512_256 or keccak256")
+	valueHex := flags.String("value", "", "leaf value, without it only the path from the leaf hash is checked")
+	if err := parseArgs(flags, args, 2); err != nil {
+		return err
+	}
+	hasher, err := csmt.HasherByName(*hasherName)
+	if err != nil {
+		return err
+	}
+	proof, err := readAuditNodes(flags.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+	rootHash, err := csmt.DecodeHex(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	if len(proof) == 0 || len(proof) > 65 {
+		return errors.New("path length is invalid")
+	}
+	height := uint8(len(proof) - 1)
+	leaf := proof[len(proof)-1]
+	if *valueHex != "" {
+		value, decodeErr := csmt.DecodeHex(*valueHex)
+		if decodeErr != nil {
+			return decodeErr
+		}
+		err = proof.VerifyPathWith(hasher, height, leaf.Index, value, rootHash)
+	} else {
+		err = proof.VerifySubtreePathWith(hasher, height, 0, leaf.Index, leaf.Value, rootHash)
+	}
+	if err != nil {
+		return fmt.Errorf("proof of leaf %v is invalid: %v", leaf.Index, err)
+	}
+	_, err = fmt.Fprintf(stdout, "proof of leaf %v is valid\n", leaf.Index)
+	return err
+}
+
+func updateProof(treePath string, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("update-proof", flag.ContinueOnError)
+	if err := parseArgs(flags, args, 2); err != nil {
+		return err
+	}
+	proof, err := readAuditNodes(flags.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+	audit, err := readAuditNodes(flags.Arg(1), stdin)
+	if err != nil {
+		return err
+	}
+	if len(proof) == 0 {
+		return errors.New("proof can not be empty")
+	}
+	updated, err := proof.UpdateProofImproved(proof[len(proof)-1].Index, audit)
+	if err != nil {
+		return err
+	}
+	return writeJSON(stdout, updated)
+}
+
+func filter(treePath string, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("filter", flag.ContinueOnError)
+	height := flags.Uint("height", 48, "tree height")
+	if err := parseArgs(flags, args, 2); err != nil {
+		return err
+	}
+	if *height == 0 || *height > 63 {
+		return errors.New("tree height must be from 1 to 63")
+	}
+	audit, err := readAuditNodes(flags.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+	index, err := strconv.ParseUint(flags.Arg(1), 10, 64)
+	if err != nil {
+		return err
+	}
+	if index >= 1<<*height {
+		return fmt.Errorf("index %v is out of the tree", index)
+	}
+	filtered := audit.FilterPath(uint8(*height), index)
+	for i, n := range filtered {
+		level := uint8(*height) - uint8(i)
+		if n.Level != level || n.Index != index>>level {
+			return fmt.Errorf("audit set has no node %v at level %v", index>>level, level)
+		}
+	}
+	return writeJSON(stdout, filtered)
+}
+
+func vectors(treePath string, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("vectors", flag.ContinueOnError)
+	seed := flags.Int64("seed", 1, "seed of the random tree")
+	height := flags.Uint("height", 48, "tree height")
+	leaves := flags.Int("leaves", 64, "number of leaves in the tree")
+	count := flags.Int("count", 8, "number of leaves to make vectors for")
+	if err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+	if *height == 0 || *height > 63 {
+		return errors.New("tree height must be from 1 to 63")
+	}
+	generated, err := onchain.GenerateVectors(*seed, uint8(*height), *leaves, *count)
+	if err != nil {
+		return err
+	}
+	return writeJSON(stdout, generated)
+}
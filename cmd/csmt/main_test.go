@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+	"github.com/matterinc/PlasmaCompact/onchain"
+)
+
+func runCommand(t *testing.T, stdin string, args ...string) string {
+	var stdout bytes.Buffer
+	if err := run(args, strings.NewReader(stdin), &stdout); err != nil {
+		t.Fatal(err)
+	}
+	return stdout.String()
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	tree := filepath.Join(dir, "tree.csmt")
+	runCommand(t, "", "-tree", tree, "init", "-height", "16")
+	if err := run([]string{"-tree", tree, "init"}, nil, &bytes.Buffer{}); err == nil {
+		t.Fatal("Existing tree was overwritten")
+	}
+
+	batch := `[{"index": 3, "value": "0x01"}, {"index": 900, "value": "0x02"}, {"index": 901, "value": "0x03"}]`
+	audit := runCommand(t, batch, "-tree", tree, "insert", "-")
+	var inserted csmt.AuditNodes
+	if err := json.Unmarshal([]byte(audit), &inserted); err != nil {
+		t.Fatal(err)
+	}
+	root := strings.TrimSpace(runCommand(t, "", "-tree", tree, "root"))
+	if root != csmt.EncodeHex(inserted[0].Value) {
+		t.Fatal("Root does not match the audit set")
+	}
+	if err := run([]string{"-tree", tree, "insert", "-"}, strings.NewReader("index,value\n3,0x05\n"), &bytes.Buffer{}); err == nil {
+		t.Fatal("Occupied leaf was inserted")
+	}
+
+	proofFile := writeFile(t, dir, "proof.json", runCommand(t, "", "-tree", tree, "prove", "900"))
+	filtered := runCommand(t, "", "filter", "-height", "16", writeFile(t, dir, "inserted.json", audit), "900")
+	if filtered != runCommand(t, "", "-tree", tree, "prove", "900") {
+		t.Fatal("Filtered proof does not match")
+	}
+	runCommand(t, "", "verify", "-value", "0x02", proofFile, root)
+	runCommand(t, "", "verify", proofFile, root)
+	if err := run([]string{"verify", "-value", "0x03", proofFile, root}, nil, &bytes.Buffer{}); err == nil {
+		t.Fatal("Proof was valid for another value")
+	}
+
+	auditFile := writeFile(t, dir, "deleted.json", runCommand(t, "# spent\n3\n901\n", "-tree", tree, "delete", "-"))
+	if err := run([]string{"-tree", tree, "delete", "-"}, strings.NewReader("[3]"), &bytes.Buffer{}); err == nil {
+		t.Fatal("Empty leaf was deleted")
+	}
+	root = strings.TrimSpace(runCommand(t, "", "-tree", tree, "root"))
+	updated := writeFile(t, dir, "updated.json", runCommand(t, "", "update-proof", proofFile, auditFile))
+	runCommand(t, "", "verify", "-value", "0x02", updated, root)
+
+	var s treeStats
+	if err := json.Unmarshal([]byte(runCommand(t, "", "-tree", tree, "stats")), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Height != 16 || s.Leaves != 1 || s.Root != root || s.Hasher != "sha512_256" {
+		t.Fatal("Invalid stats")
+	}
+}
+
+func TestFilterAuditSet(t *testing.T) {
+	dir := t.TempDir()
+	tree := filepath.Join(dir, "tree.csmt")
+	runCommand(t, "", "-tree", tree, "init", "-height", "16")
+	audit := writeFile(t, dir, "audit.json", runCommand(t, "1,0x01\n2,0x02\n40000,0x03\n", "-tree", tree, "insert", "-"))
+	if runCommand(t, "", "filter", "-height", "16", audit, "40000") != runCommand(t, "", "-tree", tree, "prove", "40000") {
+		t.Fatal("Filtered proof does not match")
+	}
+	if err := run([]string{"filter", "-height", "16", audit, "7"}, nil, &bytes.Buffer{}); err == nil {
+		t.Fatal("Proof of a leaf out of the audit set was filtered")
+	}
+}
+
+func TestVectors(t *testing.T) {
+	out := runCommand(t, "", "vectors", "-height", "16", "-leaves", "10", "-count", "2")
+	var generated []onchain.TestVector
+	if err := json.Unmarshal([]byte(out), &generated); err != nil {
+		t.Fatal(err)
+	}
+	if len(generated) != 8 || !generated[0].Valid || generated[1].Valid {
+		t.Fatal("Unexpected vectors")
+	}
+	if err := run([]string{"vectors", "-leaves", "1"}, nil, &bytes.Buffer{}); err == nil {
+		t.Fatal("Vectors of a single leaf tree were generated")
+	}
+}
@@ -0,0 +1,322 @@
+package compactplasmasmt
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// Canonical order of an audit set is the pre-order of the tree: a node goes before its
+// children and the left subtree goes before the right one. This is the order ApplyInserts
+// already produces, and in this order every subtree is a contiguous range of the set.
+
+// auditLess sorts by the left-most leaf under the node, parents go before their children.
+func auditLess(a, b AuditNode) bool {
+	leftA := a.Index << a.Level
+	leftB := b.Index << b.Level
+	if leftA != leftB {
+		return leftA < leftB
+	}
+	return a.Level > b.Level
+}
+
+// Canonical returns a copy of the audit set in the canonical order.
+func (d AuditNodes) Canonical() (AuditNodes, error) {
+	sorted := make(AuditNodes, len(d))
+	copy(sorted, d)
+	sort.SliceStable(sorted, func(i, j int) bool { return auditLess(sorted[i], sorted[j]) })
+	if err := sorted.checkCanonical(); err != nil {
+		return nil, err
+	}
+	return sorted, nil
+}
+
+func (d AuditNodes) checkCanonical() error {
+	for i := 1; i < len(d); i++ {
+		if !auditLess(d[i-1], d[i]) {
+			if d[i-1].Level == d[i].Level && d[i-1].Index == d[i].Index {
+				return errors.New("Duplicate node in audit set")
+			}
+			return errors.New("Audit set is not in canonical order")
+		}
+	}
+	return nil
+}
+
+// Encode returns a binary encoding of the node:
+// level | index (8 bytes) | length and value | length and left sibling | length and right sibling
+// Lengths are uvarints, so hashes, which are shorter than 128 bytes, take a single length byte.
+func (n AuditNode) Encode() []byte {
+	encoded := make([]byte, 9, 12+len(n.Value)+len(n.LeftSibling)+len(n.RightSibling))
+	encoded[0] = n.Level
+	binary.BigEndian.PutUint64(encoded[1:], n.Index)
+	for _, field := range [][]byte{n.Value, n.LeftSibling, n.RightSibling} {
+		encoded = binary.AppendUvarint(encoded, uint64(len(field)))
+		encoded = append(encoded, field...)
+	}
+	return encoded
+}
+
+// DecodeAuditNode decodes a single node and returns the number of bytes consumed.
+func DecodeAuditNode(data []byte) (AuditNode, int, error) {
+	var n AuditNode
+	if len(data) < 9 {
+		return n, 0, errors.New("Audit node is too short")
+	}
+	n.Level = data[0]
+	n.Index = binary.BigEndian.Uint64(data[1:9])
+	offset := 9
+	fields := make([][]byte, 3)
+	for i := range fields {
+		length, used := binary.Uvarint(data[offset:])
+		if used <= 0 {
+			return n, 0, errors.New("Audit node is too short")
+		}
+		// only the shortest length encoding is accepted, so every node has a single encoding
+		if used != len(binary.AppendUvarint(nil, length)) {
+			return n, 0, errors.New("Invalid audit node field length")
+		}
+		offset += used
+		if uint64(len(data)-offset) < length {
+			return n, 0, errors.New("Audit node is too short")
+		}
+		if length != 0 {
+			fields[i] = append([]byte{}, data[offset:offset+int(length)]...)
+		}
+		offset += int(length)
+	}
+	n.Value, n.LeftSibling, n.RightSibling = fields[0], fields[1], fields[2]
+	return n, offset, nil
+}
+
+// Encode returns the number of nodes (8 bytes) followed by the encoded nodes.
+func (d AuditNodes) Encode() []byte {
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, uint64(len(d)))
+	for _, n := range d {
+		encoded = append(encoded, n.Encode()...)
+	}
+	return encoded
+}
+
+func DecodeAuditNodes(data []byte) (AuditNodes, error) {
+	if len(data) < 8 {
+		return nil, errors.New("Audit set is too short")
+	}
+	count := binary.BigEndian.Uint64(data[:8])
+	// every node takes at least 12 bytes
+	if count > uint64(len(data)-8)/12 {
+		return nil, errors.New("Audit set is too short")
+	}
+	nodes := make(AuditNodes, count)
+	offset := 8
+	for i := range nodes {
+		n, used, err := DecodeAuditNode(data[offset:])
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = n
+		offset += used
+	}
+	if offset != len(data) {
+		return nil, errors.New("Trailing bytes after audit set")
+	}
+	return nodes, nil
+}
+
+func (d AuditNodes) encodedItems() [][]byte {
+	items := make([][]byte, len(d))
+	for i, n := range d {
+		items[i] = n.Encode()
+	}
+	return items
+}
+
+// Commitment returns a Merkle root over the audit set to be included into a block header.
+// The set must be in the canonical order.
+func (d AuditNodes) Commitment() ([]byte, error) {
+	if err := d.checkCanonical(); err != nil {
+		return nil, err
+	}
+	return ListRoot(d.encodedItems()), nil
+}
+
+// AuditChunk is a part of a committed audit set covering a single subtree. Besides the
+// nodes of the subtree it holds the subtree's ancestors, so a proof can be updated up to
+// the root, and the closest nodes on both sides, so the client can check that nothing
+// was left out.
+type AuditChunk struct {
+	Total     uint64   // number of nodes in the full audit set
+	Positions []uint64 // positions of the nodes in the full audit set
+	Nodes     AuditNodes
+	Proof     [][]byte
+}
+
+func inSubtree(n AuditNode, level uint8, nodeID uint64) bool {
+	return n.Level <= level && n.Index>>(level-n.Level) == nodeID
+}
+
+// Chunk returns the part of the canonical audit set under the node at a given level.
+func (d AuditNodes) Chunk(level uint8, nodeID uint64) (*AuditChunk, error) {
+	if err := d.checkCanonical(); err != nil {
+		return nil, err
+	}
+	if len(d) == 0 {
+		return nil, errors.New("Audit set is empty")
+	}
+	subtreeRoot := AuditNode{Level: level, Index: nodeID}
+	first := sort.Search(len(d), func(i int) bool {
+		return !auditLess(d[i], subtreeRoot)
+	})
+	last := first
+	for last < len(d) && inSubtree(d[last], level, nodeID) {
+		last++
+	}
+	positions := make([]int, 0, last-first+2)
+	// the root goes first in the canonical order, so it bounds the ancestors' levels
+	for l := level + 1; l > level && l <= d[0].Level; l++ {
+		ancestor := AuditNode{Level: l, Index: nodeID >> (l - level)}
+		i := sort.Search(first, func(i int) bool {
+			return !auditLess(d[i], ancestor)
+		})
+		if i < first && d[i].Level == l && d[i].Index == ancestor.Index {
+			positions = append(positions, i)
+		}
+	}
+	if first > 0 {
+		positions = append(positions, first-1)
+	}
+	for i := first; i < last; i++ {
+		positions = append(positions, i)
+	}
+	if last < len(d) {
+		positions = append(positions, last)
+	}
+	sort.Ints(positions)
+	unique := positions[:0]
+	for i, p := range positions {
+		if i == 0 || positions[i-1] != p {
+			unique = append(unique, p)
+		}
+	}
+	proof, err := ListProof(d.encodedItems(), unique)
+	if err != nil {
+		return nil, err
+	}
+	chunk := &AuditChunk{Total: uint64(len(d)), Proof: proof}
+	for _, p := range unique {
+		chunk.Positions = append(chunk.Positions, uint64(p))
+		chunk.Nodes = append(chunk.Nodes, d[p])
+	}
+	return chunk, nil
+}
+
+// Verify checks the chunk against the audit set commitment and returns the ancestors and
+// the nodes of the subtree in the canonical order. The subtree part may be empty if the
+// subtree was not touched.
+func (c *AuditChunk) Verify(commitment []byte, level uint8, nodeID uint64) (AuditNodes, error) {
+	if len(c.Positions) != len(c.Nodes) {
+		return nil, errors.New("Number of nodes does not match the positions")
+	}
+	if err := c.Nodes.checkCanonical(); err != nil {
+		return nil, err
+	}
+	positions := make([]int, len(c.Positions))
+	for i, p := range c.Positions {
+		if p >= c.Total {
+			return nil, errors.New("Position is out of the audit set")
+		}
+		positions[i] = int(p)
+	}
+	err := VerifyListProof(commitment, int(c.Total), positions, c.Nodes.encodedItems(), c.Proof)
+	if err != nil {
+		return nil, err
+	}
+	subtreeRoot := AuditNode{Level: level, Index: nodeID}
+	before := sort.Search(len(c.Nodes), func(i int) bool {
+		return !auditLess(c.Nodes[i], subtreeRoot)
+	})
+	inside := before
+	for inside < len(c.Nodes) && inSubtree(c.Nodes[inside], level, nodeID) {
+		inside++
+	}
+	// the nodes right before and right after the subtree must be present
+	previous := -1
+	if before > 0 {
+		previous = positions[before-1]
+	}
+	next := int(c.Total)
+	if inside < len(positions) {
+		next = positions[inside]
+	}
+	if next-previous-1 != inside-before {
+		return nil, errors.New("Chunk does not cover the whole subtree")
+	}
+	if before > 0 {
+		if err := c.checkAncestors(before, inside, level, nodeID); err != nil {
+			return nil, err
+		}
+	}
+	var nodes AuditNodes
+	for _, n := range c.Nodes[:before] {
+		if n.Level > level && n.Index == nodeID>>(n.Level-level) {
+			nodes = append(nodes, n)
+		}
+	}
+	return append(nodes, c.Nodes[before:inside]...), nil
+}
+
+// checkAncestors checks that no ancestor of the subtree was left out. The audit set holds
+// whole paths from the root, so a touched subtree has all of its ancestors in the set. An
+// untouched one has them down to the lowest ancestor shared with the node right before
+// the subtree, deeper ones would come between that node and the subtree.
+func (c *AuditChunk) checkAncestors(before, inside int, level uint8, nodeID uint64) error {
+	if c.Positions[0] != 0 {
+		return errors.New("Chunk does not start with the root")
+	}
+	rootLevel := c.Nodes[0].Level
+	lowest := level + 1
+	if inside == before {
+		n := c.Nodes[before-1]
+		if n.Level > lowest {
+			lowest = n.Level
+		}
+		for lowest < rootLevel && n.Index>>(lowest-n.Level) != nodeID>>(lowest-level) {
+			lowest++
+		}
+	}
+	present := make(map[uint8]bool)
+	for _, n := range c.Nodes[:before] {
+		if n.Level > level && n.Index == nodeID>>(n.Level-level) {
+			present[n.Level] = true
+		}
+	}
+	for l := lowest; l <= rootLevel && l > level; l++ {
+		if !present[l] {
+			return errors.New("Chunk misses an ancestor of the subtree")
+		}
+	}
+	return nil
+}
+
+// MergeAuditNodes joins audit sets produced one after another on the same tree, like the
+// deletion and insertion passes of a block. For nodes touched more than once the latest
+// version wins. The result is in the canonical order.
+func MergeAuditNodes(sets ...AuditNodes) AuditNodes {
+	latest := make(map[string]int)
+	var merged AuditNodes
+	for _, set := range sets {
+		for _, n := range set {
+			key := cacheKey(n.Level, n.Index)
+			if i, exists := latest[key]; exists {
+				merged[i] = n
+				continue
+			}
+			latest[key] = len(merged)
+			merged = append(merged, n)
+		}
+	}
+	// keys are unique, so the merged set can always be sorted
+	canonical, _ := merged.Canonical()
+	return canonical
+}
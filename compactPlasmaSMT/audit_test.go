@@ -0,0 +1,158 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func auditTestSet() AuditNodes {
+	csmt := NewCSMT(8, false)
+	toInsert := make(InsertionIndexes, 4)
+	for i, idx := range []uint64{3, 64, 70, 250} {
+		toInsert[i].Index = idx
+		toInsert[i].Value = []byte{byte(i + 1)}
+	}
+	return csmt.ApplyInserts(toInsert)
+}
+
+func TestAuditSetIsCanonical(t *testing.T) {
+	path := auditTestSet()
+	canonical, err := path.Canonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range path {
+		if path[i].Level != canonical[i].Level || path[i].Index != canonical[i].Index {
+			t.Fatal("ApplyInserts output is not in the canonical order")
+		}
+	}
+	shuffled := append(AuditNodes{}, path[len(path)-1])
+	shuffled = append(shuffled, path[:len(path)-1]...)
+	if _, err := shuffled.Commitment(); err == nil {
+		t.Fatal("Commitment accepted a non-canonical set")
+	}
+	decoded, err := DecodeAuditNodes(path.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := path.Commitment()
+	second, _ := decoded.Commitment()
+	if bytes.Compare(first, second) != 0 {
+		t.Fatal("Decoded audit set has a different commitment")
+	}
+}
+
+func TestAuditNodeLongFields(t *testing.T) {
+	n := AuditNode{3, 1, bytes.Repeat([]byte{0x01}, 300), nil, bytes.Repeat([]byte{0x02}, 32)}
+	encoded := n.Encode()
+	decoded, used, err := DecodeAuditNode(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != len(encoded) || bytes.Compare(decoded.Value, n.Value) != 0 || decoded.LeftSibling != nil ||
+		bytes.Compare(decoded.RightSibling, n.RightSibling) != 0 {
+		t.Fatal("Node with a long value changed after a round trip")
+	}
+	// zero length of the left sibling written in two bytes
+	padded := append(append(append([]byte{}, encoded[:311]...), 0x80, 0x00), encoded[312:]...)
+	if _, _, err := DecodeAuditNode(padded); err == nil {
+		t.Fatal("Non-canonical field length was accepted")
+	}
+	if _, _, err := DecodeAuditNode(encoded[:len(encoded)-1]); err == nil {
+		t.Fatal("Truncated node was decoded")
+	}
+}
+
+func TestAuditChunk(t *testing.T) {
+	path := auditTestSet()
+	commitment, err := path.Commitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// subtree of leaves 64..79 holds two inserted leaves
+	chunk, err := path.Chunk(4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := chunk.Verify(commitment, 4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := nodes.FilterPath(8, 70)
+	err = filtered.VefiryPath(8, 70, []byte{0x03}, path[0].Value)
+	if err != nil {
+		t.Fatal("Chunk is not enough to build a proof")
+	}
+	// untouched subtree gives no nodes but is still authenticated
+	chunk, err = path.Chunk(4, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err = chunk.Verify(commitment, 4, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range nodes {
+		if n.Level <= 4 {
+			t.Fatal("Untouched subtree has nodes")
+		}
+	}
+}
+
+func TestAuditChunkRejectsMissingNodes(t *testing.T) {
+	path := auditTestSet()
+	commitment, _ := path.Commitment()
+	chunk, _ := path.Chunk(4, 4)
+	for i, n := range chunk.Nodes {
+		if n.Level == 0 && n.Index == 70 {
+			chunk.Nodes = append(chunk.Nodes[:i], chunk.Nodes[i+1:]...)
+			chunk.Positions = append(chunk.Positions[:i], chunk.Positions[i+1:]...)
+			break
+		}
+	}
+	if _, err := chunk.Verify(commitment, 4, 4); err == nil {
+		t.Fatal("Incomplete chunk was accepted")
+	}
+	// an ancestor is left out and the proof is rebuilt for the remaining positions
+	chunk, _ = path.Chunk(4, 4)
+	for i, n := range chunk.Nodes {
+		if n.Level == 6 && n.Index == 1 {
+			chunk.Nodes = append(chunk.Nodes[:i], chunk.Nodes[i+1:]...)
+			chunk.Positions = append(chunk.Positions[:i], chunk.Positions[i+1:]...)
+			break
+		}
+	}
+	positions := make([]int, len(chunk.Positions))
+	for i, p := range chunk.Positions {
+		positions[i] = int(p)
+	}
+	chunk.Proof, _ = ListProof(path.encodedItems(), positions)
+	if _, err := chunk.Verify(commitment, 4, 4); err == nil {
+		t.Fatal("Chunk without an ancestor was accepted")
+	}
+	chunk, _ = path.Chunk(4, 4)
+	chunk.Nodes[len(chunk.Nodes)-1].Value = []byte{0x00}
+	if _, err := chunk.Verify(commitment, 4, 4); err == nil {
+		t.Fatal("Modified chunk was accepted")
+	}
+}
+
+func TestListProof(t *testing.T) {
+	items := make([][]byte, 11)
+	for i := range items {
+		items[i] = []byte{byte(i)}
+	}
+	root := ListRoot(items)
+	positions := []int{0, 4, 5, 6, 10}
+	proof, err := ListProof(items, positions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selected := [][]byte{items[0], items[4], items[5], items[6], items[10]}
+	if err := VerifyListProof(root, len(items), positions, selected, proof); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyListProof(root, len(items)+1, positions, selected, proof); err == nil {
+		t.Fatal("Proof was accepted for a different list length")
+	}
+}
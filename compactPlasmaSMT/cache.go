@@ -71,3 +71,16 @@ func (c CacheBranch) Print() {
 		log.Printf("For key %v value = %v", []byte(k), v)
 	}
 }
+
+// cacheKey builds the same index that is used by all the cache methods.
+func cacheKey(height uint8, nodeID uint64) string {
+	index := make([]byte, 9)
+	index[0] = height
+	binary.BigEndian.PutUint64(index[1:], nodeID)
+	return string(index)
+}
+
+// parseCacheKey is an inverse of cacheKey.
+func parseCacheKey(key string) (height uint8, nodeID uint64) {
+	return key[0], binary.BigEndian.Uint64([]byte(key[1:]))
+}
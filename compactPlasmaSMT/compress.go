@@ -0,0 +1,242 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Compressed audit set layout:
+//
+//	number of touched leaves (uvarint)
+//	leaf indexes in increasing order, the first one as is and then deltas (uvarints)
+//	bitmap with a bit per leaf, set if the leaf value is known to the reader
+//	values in pre-order, each one a length byte and the value:
+//	  the value of every leaf that is not known
+//	  the untouched sibling of every node with a single touched child
+//
+// Everything else follows from the leaf indexes: the touched nodes are the union of the paths
+// to the leaves, so the upper levels are written once, and node values and the siblings that
+// are themselves touched are recomputed bottom up.
+
+// CompressAudit compresses an audit set of a batch of leaf changes. known maps leaf indexes
+// to leaf hashes the reader has on its own, nil for an empty leaf, usually the leaves touched
+// by a block. Only audit sets that are exactly the paths to bottom level leaves, like the
+// ones of ApplyInserts and ApplyDeletes or merges of them, can be compressed.
+func (d AuditNodes) CompressAudit(h Hasher, height uint8, known map[uint64][]byte) ([]byte, error) {
+	if height > 64 {
+		return nil, errors.New("Tree height is out of range")
+	}
+	canonical, err := d.Canonical()
+	if err != nil {
+		return nil, err
+	}
+	var leaves AuditNodes
+	for _, n := range canonical {
+		if n.Level == 0 {
+			leaves = append(leaves, n)
+		}
+	}
+	encoded := binary.AppendUvarint(nil, uint64(len(leaves)))
+	for i, n := range leaves {
+		delta := n.Index
+		if i != 0 {
+			delta -= leaves[i-1].Index
+		}
+		encoded = binary.AppendUvarint(encoded, delta)
+	}
+	c := &auditCompressor{known: known, leaves: leaves, nodes: make(map[string]AuditNode, len(canonical)), bitmap: make([]byte, (len(leaves)+7)/8)}
+	for _, n := range canonical {
+		c.nodes[cacheKey(n.Level, n.Index)] = n
+	}
+	if len(leaves) != 0 {
+		c.node(height, 0, 0, len(leaves))
+	}
+	encoded = append(encoded, c.bitmap...)
+	for _, v := range c.values {
+		if len(v) > 255 {
+			return nil, errors.New("Audit node value is too long")
+		}
+		encoded = append(encoded, uint8(len(v)))
+		encoded = append(encoded, v...)
+	}
+	// the format can only express well formed sets, check that nothing was lost
+	decoded, err := DecompressAudit(h, height, encoded, known)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) != len(canonical) {
+		return nil, errors.New("Audit set is not the paths to its leaves")
+	}
+	for i := range decoded {
+		if err := compareAuditNode(canonical[i], decoded[i]); err != nil {
+			return nil, fmt.Errorf("Audit set can not be compressed: %v", err)
+		}
+	}
+	return encoded, nil
+}
+
+type auditCompressor struct {
+	known  map[uint64][]byte
+	leaves AuditNodes
+	nodes  map[string]AuditNode
+	bitmap []byte
+	values [][]byte
+}
+
+// node collects the values of the subtree over leaves[first:last] in the order the
+// decompressor reads them. Missing nodes are skipped, the round trip check catches them.
+func (c *auditCompressor) node(level uint8, index uint64, first, last int) {
+	if level == 0 {
+		leaf := c.leaves[first]
+		if hash, exists := c.known[leaf.Index]; exists && bytes.Compare(hash, leaf.Value) == 0 {
+			c.bitmap[first/8] |= 1 << uint(first%8)
+			return
+		}
+		c.values = append(c.values, leaf.Value)
+		return
+	}
+	n := c.nodes[cacheKey(level, index)]
+	split := first
+	for split < last && (c.leaves[split].Index>>(level-1))&1 == 0 {
+		split++
+	}
+	if split != first {
+		c.node(level-1, index<<1, first, split)
+	} else {
+		c.values = append(c.values, n.LeftSibling)
+	}
+	if split != last {
+		c.node(level-1, index<<1+1, split, last)
+	} else {
+		c.values = append(c.values, n.RightSibling)
+	}
+}
+
+type auditDecompressor struct {
+	h      Hasher
+	known  map[uint64][]byte
+	leaves []uint64
+	bitmap []byte
+	data   []byte
+	nodes  AuditNodes
+}
+
+// DecompressAudit rebuilds an audit set in the canonical order from CompressAudit output and
+// the same known leaves.
+func DecompressAudit(h Hasher, height uint8, data []byte, known map[uint64][]byte) (AuditNodes, error) {
+	if height > 64 {
+		return nil, errors.New("Tree height is out of range")
+	}
+	count, used := binary.Uvarint(data)
+	if used <= 0 {
+		return nil, errors.New("Compressed audit set is too short")
+	}
+	data = data[used:]
+	// every index takes at least a byte
+	if count > uint64(len(data)) {
+		return nil, errors.New("Compressed audit set is too short")
+	}
+	if count == 0 {
+		if len(data) != 0 {
+			return nil, errors.New("Trailing bytes after compressed audit set")
+		}
+		return nil, nil
+	}
+	c := &auditDecompressor{h: h, known: known, leaves: make([]uint64, count)}
+	for i := range c.leaves {
+		delta, used := binary.Uvarint(data)
+		if used <= 0 {
+			return nil, errors.New("Invalid leaf index in compressed audit set")
+		}
+		data = data[used:]
+		if i == 0 {
+			c.leaves[i] = delta
+			continue
+		}
+		if delta == 0 || c.leaves[i-1]+delta < c.leaves[i-1] {
+			return nil, errors.New("Leaf indexes are not increasing")
+		}
+		c.leaves[i] = c.leaves[i-1] + delta
+	}
+	if height < 64 && c.leaves[count-1] >= 1<<height {
+		return nil, errors.New("Leaf index is out of range")
+	}
+	size := int(count+7) / 8
+	if len(data) < size {
+		return nil, errors.New("Compressed audit set is too short")
+	}
+	c.bitmap, c.data = data[:size], data[size:]
+	if count%8 != 0 && c.bitmap[size-1]>>(count%8) != 0 {
+		return nil, errors.New("Non zero padding in leaf bitmap")
+	}
+	if _, err := c.node(height, 0, 0, c.leaves); err != nil {
+		return nil, err
+	}
+	if len(c.data) != 0 {
+		return nil, errors.New("Trailing bytes after compressed audit set")
+	}
+	return c.nodes, nil
+}
+
+func (c *auditDecompressor) value() ([]byte, error) {
+	if len(c.data) == 0 || len(c.data) < 1+int(c.data[0]) {
+		return nil, errors.New("Compressed audit set is too short")
+	}
+	length := int(c.data[0])
+	var v []byte
+	if length != 0 {
+		v = append([]byte{}, c.data[1:1+length]...)
+	}
+	c.data = c.data[1+length:]
+	return v, nil
+}
+
+// node appends the subtree of touched nodes in pre-order and returns the node value.
+// first is the position of the first leaf of the subtree, leaves are the leaves under it.
+func (c *auditDecompressor) node(level uint8, index uint64, first int, leaves []uint64) ([]byte, error) {
+	if level == 0 {
+		n := AuditNode{Level: 0, Index: index}
+		if c.bitmap[first/8]&(1<<uint(first%8)) != 0 {
+			hash, exists := c.known[index]
+			if !exists {
+				return nil, errors.New("Leaf is not known")
+			}
+			n.Value = hash
+		} else {
+			v, err := c.value()
+			if err != nil {
+				return nil, err
+			}
+			n.Value = v
+		}
+		c.nodes = append(c.nodes, n)
+		return n.Value, nil
+	}
+	position := len(c.nodes)
+	c.nodes = append(c.nodes, AuditNode{Level: level, Index: index})
+	split := 0
+	for split < len(leaves) && (leaves[split]>>(level-1))&1 == 0 {
+		split++
+	}
+	var left, right []byte
+	var err error
+	if split != 0 {
+		if left, err = c.node(level-1, index<<1, first, leaves[:split]); err != nil {
+			return nil, err
+		}
+	} else if left, err = c.value(); err != nil {
+		return nil, err
+	}
+	if split != len(leaves) {
+		if right, err = c.node(level-1, index<<1+1, first+split, leaves[split:]); err != nil {
+			return nil, err
+		}
+	} else if right, err = c.value(); err != nil {
+		return nil, err
+	}
+	c.nodes[position].Value = c.h.NodeHash(left, right)
+	c.nodes[position].LeftSibling, c.nodes[position].RightSibling = left, right
+	return c.nodes[position].Value, nil
+}
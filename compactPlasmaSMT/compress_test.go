@@ -0,0 +1,150 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestCompressAudit(t *testing.T) {
+	csmt := NewCSMT(treeHeight, true)
+	var toInsert InsertionIndexes
+	for i := uint64(0); i < 200; i++ {
+		toInsert = append(toInsert, InsertedIndex{Index: UTXOIndex(1, i*7, i%3), Value: []byte{byte(i), 0x01}})
+	}
+	_ = csmt.ApplyInserts(toInsert)
+	ours := UTXOIndex(1, 5*7, 2)
+	proof := csmt.Prove(ours)
+
+	known := make(map[uint64][]byte)
+	deletions := DeletionIndexes{UTXOIndex(1, 0, 0), UTXOIndex(1, 9*7, 0), UTXOIndex(1, 100*7, 1)}
+	for _, index := range deletions {
+		known[index] = nil
+	}
+	toInsert = nil
+	for i := uint64(0); i < 50; i++ {
+		index := UTXOIndex(2, i, 0)
+		toInsert = append(toInsert, InsertedIndex{Index: index, Value: []byte{byte(i), 0x02}})
+		known[index] = LeafHash(toInsert[i].Value)
+	}
+	audit := MergeAuditNodes(csmt.ApplyDeletes(deletions), csmt.ApplyInserts(toInsert))
+
+	compressed, err := audit.CompressAudit(SHA512_256, treeHeight, known)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed)*3 > len(audit.Encode()) {
+		t.Fatalf("Compressed audit set takes %v bytes out of %v", len(compressed), len(audit.Encode()))
+	}
+	decoded, err := DecompressAudit(SHA512_256, treeHeight, compressed, known)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(audit) {
+		t.Fatal("Decompressed audit set has a different size")
+	}
+	for i := range audit {
+		if err := compareAuditNode(audit[i], decoded[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	updated, err := proof.UpdateProofImproved(ours, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := updated.VefiryPath(treeHeight, ours, csmt.Leaf(ours), csmt.RootHash()); err != nil {
+		t.Fatal(err)
+	}
+
+	// without the known leaves their values are written out
+	full, err := audit.CompressAudit(SHA512_256, treeHeight, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full) <= len(compressed) {
+		t.Fatal("Known leaves were not left out")
+	}
+	if _, err := DecompressAudit(SHA512_256, treeHeight, compressed, nil); err == nil {
+		t.Fatal("Audit set was decompressed without the known leaves")
+	}
+}
+
+func TestCompressBlockAudit(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	csmt := NewCSMT(treeHeight, false)
+	for block := uint64(1); block <= 2; block++ {
+		seen := make(map[uint64]bool)
+		var toInsert InsertionIndexes
+		for i := 0; i < 10000; i++ {
+			index := UTXOIndex(block, uint64(rnd.Intn(1<<transactionPrefixBits)), uint64(rnd.Intn(1<<outputPrefixBits)))
+			if seen[index] {
+				continue
+			}
+			seen[index] = true
+			value := make([]byte, 32+64)
+			rnd.Read(value)
+			toInsert = append(toInsert, InsertedIndex{index, value})
+		}
+		sort.Sort(toInsert)
+		audit := csmt.ApplyInserts(toInsert)
+		if block == 1 {
+			continue
+		}
+		known := make(map[uint64][]byte, len(toInsert))
+		for _, inserted := range toInsert {
+			known[inserted.Index] = LeafHash(inserted.Value)
+		}
+		compressed, err := audit.CompressAudit(SHA512_256, treeHeight, known)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("Audit data of a block of %v outputs is %v bytes, %v bytes compressed", len(toInsert), len(audit.Encode()), len(compressed))
+		if len(compressed)*10 > len(audit.Encode()) {
+			t.Fatal("Audit data of a full block was not compressed")
+		}
+		decoded, err := DecompressAudit(SHA512_256, treeHeight, compressed, known)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded) != len(audit) || bytes.Compare(decoded[0].Value, csmt.RootHash()) != 0 {
+			t.Fatal("Decompressed audit set does not match")
+		}
+	}
+}
+
+func TestCompressAuditRejectsMalformed(t *testing.T) {
+	csmt := NewCSMT(16, true)
+	audit := csmt.ApplyInserts(InsertionIndexes{{Index: 3, Value: []byte{0x01}}, {Index: 900, Value: []byte{0x02}}})
+	compressed, err := audit.CompressAudit(SHA512_256, 16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, malformed := range [][]byte{
+		compressed[:len(compressed)-1],
+		append(append([]byte{}, compressed...), 0x00),
+		{0x02, 0x03, 0x00, 0x00}, // repeated leaf index
+		{0x01, 0x80, 0x80, 0x04}, // leaf index out of range
+		{0x01, 0x03, 0x02, 0x00}, // padding bit set in the bitmap
+		{0x05},                   // more leaves than bytes
+	} {
+		if _, err := DecompressAudit(SHA512_256, 16, malformed, nil); err == nil {
+			t.Fatalf("Malformed input %x was decompressed", malformed)
+		}
+	}
+
+	// sets that are not exactly the paths to their leaves can not be expressed
+	if _, err := audit[1:].CompressAudit(SHA512_256, 16, nil); err == nil {
+		t.Fatal("Audit set without the root was compressed")
+	}
+	extra := append(AuditNodes{}, audit...)
+	extra = append(extra, AuditNode{1, 100, LeafHash([]byte{5}), nil, nil})
+	if _, err := extra.CompressAudit(SHA512_256, 16, nil); err == nil {
+		t.Fatal("Audit set with an extra node was compressed")
+	}
+	forged := append(AuditNodes{}, audit...)
+	forged[0].Value = LeafHash([]byte{0xff})
+	if _, err := forged.CompressAudit(SHA512_256, 16, nil); err == nil {
+		t.Fatal("Audit set with an invalid value was compressed")
+	}
+}
@@ -18,27 +18,11 @@ const (
 )
 
 func NodeHash(left, right []byte) []byte {
-	if left == nil && right == nil {
-		return nil
-	} else if right == nil {
-		hasher := sha512.New512_256()
-		hasher.Write(left)
-		return hasher.Sum(nil)
-	} else if left == nil {
-		hasher := sha512.New512_256()
-		hasher.Write(right)
-		return hasher.Sum(nil)
-	}
-	hasher := sha512.New512_256()
-	hasher.Write(left)
-	hasher.Write(right)
-	return hasher.Sum(nil)
+	return compactNodeHash(sha512.New512_256, left, right)
 }
 
 func LeafHash(leaf []byte) []byte {
-	hasher := sha512.New512_256()
-	hasher.Write(leaf)
-	return hasher.Sum(nil)
+	return leafHash(sha512.New512_256, leaf)
 }
 
 type InsertedIndex struct {
@@ -113,15 +97,82 @@ func (d AuditNodes) Less(i, j int) bool { return d[i].Index < d[j].Index }
 // SMT is a sparse Merkle tree.
 type CSMT struct {
 	cache  *CacheBranch // Cache interface could be implemented by different caching strategies
+	leaves *LeafStorage // raw leaf values, nil if leaf storage is disabled
+	hasher Hasher       // nil means SHA512_256
 	Height uint8        // key of left-most leaf of a subtree, fixed in size.
 	Root   *CSMTLevel
 }
 
 type CSMTLevel struct {
 	cache    *CacheBranch // Cache interface could be implemented by different caching strategies
+	leaves   *LeafStorage // raw leaf values, nil if leaf storage is disabled
+	hasher   Hasher       // nil means SHA512_256
 	MaxLevel uint8        // level in the global tree, bottom level == 0
 }
 
+// NewCSMT creates an empty tree of a given height. If storeLeaves is set the tree
+// also keeps raw values of live leaves, not only their hashes.
+func NewCSMT(height uint8, storeLeaves bool) *CSMT {
+	return NewCSMTWithHasher(height, storeLeaves, SHA512_256)
+}
+
+// NewCSMTWithHasher creates an empty tree that uses the given hash functions.
+func NewCSMTWithHasher(height uint8, storeLeaves bool, hasher Hasher) *CSMT {
+	cache := make(CacheBranch)
+	var leaves *LeafStorage
+	if storeLeaves {
+		l := make(LeafStorage)
+		leaves = &l
+	}
+	return &CSMT{
+		cache:  &cache,
+		leaves: leaves,
+		hasher: hasher,
+		Height: height,
+		Root:   &CSMTLevel{cache: &cache, leaves: leaves, hasher: hasher, MaxLevel: height},
+	}
+}
+
+// Hasher returns the hash functions of the tree.
+func (s *CSMT) Hasher() Hasher {
+	if s.hasher == nil {
+		return SHA512_256
+	}
+	return s.hasher
+}
+
+// CacheEntries returns the number of non-empty nodes kept by the tree.
+func (s *CSMT) CacheEntries() int {
+	return s.cache.Entries()
+}
+
+func (s *CSMTLevel) nodeHash(left, right []byte) []byte {
+	if s.hasher == nil {
+		return NodeHash(left, right)
+	}
+	return s.hasher.NodeHash(left, right)
+}
+
+func (s *CSMTLevel) leafHash(leaf []byte) []byte {
+	if s.hasher == nil {
+		return LeafHash(leaf)
+	}
+	return s.hasher.LeafHash(leaf)
+}
+
+// RootHash returns the current root of the tree, nil for an empty tree.
+func (s *CSMT) RootHash() []byte {
+	return s.cache.Get(s.Height, 0)
+}
+
+// Leaf returns a raw value of the live leaf. It always returns nil if leaf storage is disabled.
+func (s *CSMT) Leaf(index uint64) []byte {
+	if s.leaves == nil {
+		return nil
+	}
+	return s.leaves.Get(index)
+}
+
 // Indexes to delete must be always sorted and only point to the bottom of the tree.
 func (s *CSMT) ApplyDeletes(d DeletionIndexes) AuditNodes {
 	return s.Root.ApplyDeletes(d, s.Height)
@@ -137,6 +188,9 @@ func (s *CSMTLevel) ApplyDeletes(d DeletionIndexes, splitLevel uint8) AuditNodes
 			log.Fatalln("Trying to delete not a single indexes at the bottom level")
 		}
 		_ = s.cache.Delete(splitLevel, d[0])
+		if s.leaves != nil {
+			s.leaves.Delete(d[0])
+		}
 		node := make(AuditNodes, 1)
 		node[0].Level = 0
 		node[0].Index = d[0]
@@ -150,25 +204,35 @@ func (s *CSMTLevel) ApplyDeletes(d DeletionIndexes, splitLevel uint8) AuditNodes
 	lenLeft := len(left)
 	lenRight := len(right)
 
+	// the untouched sibling keeps its value, so it has to be taken from the cache
 	var leftRoot AuditNode
-	if lenLeft != 0 {
-		leftRoot = left[0]
-	}
-
 	var rightRoot AuditNode
-	if lenRight != 0 {
+	if lenLeft != 0 && lenRight == 0 {
+		leftRoot = left[0]
+		cacheRecord := s.cache.Get(leftRoot.Level, leftRoot.Index+1)
+		if cacheRecord != nil {
+			rightRoot = AuditNode{leftRoot.Level, leftRoot.Index + 1, cacheRecord, nil, nil}
+		}
+	} else if lenLeft == 0 && lenRight != 0 {
+		rightRoot = right[0]
+		cacheRecord := s.cache.Get(rightRoot.Level, rightRoot.Index-1)
+		if cacheRecord != nil {
+			leftRoot = AuditNode{rightRoot.Level, rightRoot.Index - 1, cacheRecord, nil, nil}
+		}
+	} else if lenLeft != 0 && lenRight != 0 {
+		leftRoot = left[0]
 		rightRoot = right[0]
 	}
-	thisHash := NodeHash(leftRoot.Value, rightRoot.Value)
+	thisHash := s.nodeHash(leftRoot.Value, rightRoot.Value)
 	var thisID uint64
 	if lenLeft != 0 {
-		thisID = leftRoot.Index >> 1
+		thisID = left[0].Index >> 1
 	} else if lenRight != 0 {
-		thisID = rightRoot.Index >> 1
+		thisID = right[0].Index >> 1
 	} else {
 		log.Panicln("Can not get audit node index")
 	}
-	s.cache.Delete(splitLevel, thisID)
+	s.cache.UpdateAndStore(splitLevel, thisID, thisHash)
 
 	allNodes := make(AuditNodes, lenLeft+lenRight+1)
 	allNodes[0] = AuditNode{splitLevel, thisID, thisHash, leftRoot.Value, rightRoot.Value}
@@ -199,8 +263,11 @@ func (s *CSMTLevel) ApplyInserts(d InsertionIndexes, splitLevel uint8) AuditNode
 		if len(d) != 1 {
 			log.Fatalln("Trying to insert not a single indexes at the bottom level")
 		}
-		newHash := LeafHash(d[0].Value)
+		newHash := s.leafHash(d[0].Value)
 		s.cache.Insert(splitLevel, d[0].Index, newHash)
+		if s.leaves != nil {
+			s.leaves.Insert(d[0].Index, d[0].Value)
+		}
 		node := make(AuditNodes, 1)
 		node[0].Level = 0
 		node[0].Index = d[0].Index
@@ -239,7 +306,7 @@ func (s *CSMTLevel) ApplyInserts(d InsertionIndexes, splitLevel uint8) AuditNode
 		rightRoot = right[0]
 	}
 
-	thisHash := NodeHash(leftRoot.Value, rightRoot.Value)
+	thisHash := s.nodeHash(leftRoot.Value, rightRoot.Value)
 	var thisID uint64
 	if lenLeft != 0 {
 		thisID = leftRoot.Index >> 1
@@ -262,6 +329,11 @@ func (s *CSMTLevel) ApplyInserts(d InsertionIndexes, splitLevel uint8) AuditNode
 }
 
 func (p AuditNodes) VefiryPath(height uint8, index uint64, value, root []byte) error {
+	return p.VerifyPathWith(SHA512_256, height, index, value, root)
+}
+
+// VerifyPathWith is VefiryPath for a tree built with the given hasher.
+func (p AuditNodes) VerifyPathWith(h Hasher, height uint8, index uint64, value, root []byte) error {
 	if len(p) == 0 {
 		return errors.New("Path can not be zero length")
 	}
@@ -274,7 +346,7 @@ func (p AuditNodes) VefiryPath(height uint8, index uint64, value, root []byte) e
 	if p[len(p)-1].Index != index {
 		return errors.New("Most likely checking for invalid index")
 	}
-	hash := LeafHash(value)
+	hash := h.LeafHash(value)
 	if bytes.Compare(p[len(p)-1].Value, hash) != 0 {
 		return errors.New("Low level hash does not match")
 	}
@@ -283,10 +355,16 @@ func (p AuditNodes) VefiryPath(height uint8, index uint64, value, root []byte) e
 		thisP := p[i]
 		if idx&1 == 0 {
 			proof := thisP.RightSibling
-			hash = NodeHash(hash, proof)
+			if err := checkSibling(h, proof); err != nil {
+				return err
+			}
+			hash = h.NodeHash(hash, proof)
 		} else {
 			proof := thisP.LeftSibling
-			hash = NodeHash(proof, hash)
+			if err := checkSibling(h, proof); err != nil {
+				return err
+			}
+			hash = h.NodeHash(proof, hash)
 		}
 		idx = idx / 2
 	}
@@ -322,6 +400,11 @@ func (p AuditNodes) FilterPath(height uint8, index uint64) AuditNodes {
 }
 
 func (p AuditNodes) UpdateProof(index uint64, extraData AuditNodes) (AuditNodes, error) {
+	return p.UpdateProofWith(SHA512_256, index, extraData)
+}
+
+// UpdateProofWith is UpdateProof for a tree built with the given hasher.
+func (p AuditNodes) UpdateProofWith(h Hasher, index uint64, extraData AuditNodes) (AuditNodes, error) {
 	joined := make(AuditNodes, len(p))
 	maxHops := len(extraData)
 
@@ -359,7 +442,7 @@ func (p AuditNodes) UpdateProof(index uint64, extraData AuditNodes) (AuditNodes,
 					}
 				}
 				log.Printf("Found siblings with number %v at level %v", siblingLeft, expectedLevel)
-				newValue := NodeHash(extraNode.Value, currentNode.RightSibling)
+				newValue := h.NodeHash(extraNode.Value, currentNode.RightSibling)
 				joined[j] = AuditNode{currentNode.Level, currentNode.Index, newValue, extraNode.Value, currentNode.RightSibling}
 				maxHops = i + 1
 				firstIntersectionFound = true
@@ -373,7 +456,7 @@ func (p AuditNodes) UpdateProof(index uint64, extraData AuditNodes) (AuditNodes,
 					}
 				}
 				log.Printf("Found siblings with number %v at level %v", siblingRight, expectedLevel)
-				newValue := NodeHash(currentNode.LeftSibling, extraNode.Value)
+				newValue := h.NodeHash(currentNode.LeftSibling, extraNode.Value)
 				joined[j] = AuditNode{currentNode.Level, currentNode.Index, newValue, currentNode.LeftSibling, extraNode.Value}
 				maxHops = i + 1
 				firstIntersectionFound = true
@@ -393,10 +476,10 @@ func (p AuditNodes) UpdateProof(index uint64, extraData AuditNodes) (AuditNodes,
 					nextLevelNode := joined[j-1]
 					replaceLeft := currentNode.Index&1 == 0
 					if replaceLeft {
-						newValue := NodeHash(currentNode.Value, nextLevelNode.RightSibling)
+						newValue := h.NodeHash(currentNode.Value, nextLevelNode.RightSibling)
 						joined[j-1] = AuditNode{nextLevelNode.Level, nextLevelNode.Index, newValue, currentNode.Value, nextLevelNode.RightSibling}
 					} else {
-						newValue := NodeHash(nextLevelNode.LeftSibling, currentNode.Value)
+						newValue := h.NodeHash(nextLevelNode.LeftSibling, currentNode.Value)
 						joined[j-1] = AuditNode{nextLevelNode.Level, nextLevelNode.Index, newValue, nextLevelNode.LeftSibling, currentNode.Value}
 					}
 				}
@@ -412,11 +495,11 @@ func (p AuditNodes) UpdateProof(index uint64, extraData AuditNodes) (AuditNodes,
 				replaceLeft := intersectionNode.Index&1 == 0
 				if replaceLeft {
 					log.Printf("Replacing left sibling of node number %v at level %v", nextLevelNode.Index, nextLevelNode.Level)
-					newValue := NodeHash(intersectionNode.Value, nextLevelNode.RightSibling)
+					newValue := h.NodeHash(intersectionNode.Value, nextLevelNode.RightSibling)
 					joined[j-1] = AuditNode{nextLevelNode.Level, nextLevelNode.Index, newValue, intersectionNode.Value, nextLevelNode.RightSibling}
 				} else {
 					log.Printf("Replacing right sibling of node number %v at level %v", nextLevelNode.Index, nextLevelNode.Level)
-					newValue := NodeHash(nextLevelNode.LeftSibling, intersectionNode.Value)
+					newValue := h.NodeHash(nextLevelNode.LeftSibling, intersectionNode.Value)
 					joined[j-1] = AuditNode{nextLevelNode.Level, nextLevelNode.Index, newValue, nextLevelNode.LeftSibling, intersectionNode.Value}
 				}
 			}
@@ -427,10 +510,10 @@ func (p AuditNodes) UpdateProof(index uint64, extraData AuditNodes) (AuditNodes,
 		thisRoot := p[0]
 		otherRoot := extraData[0]
 		if thisRoot.LeftSibling != nil && otherRoot.RightSibling != nil {
-			newValue := NodeHash(thisRoot.LeftSibling, otherRoot.RightSibling)
+			newValue := h.NodeHash(thisRoot.LeftSibling, otherRoot.RightSibling)
 			joined[0] = AuditNode{0, 0, newValue, thisRoot.LeftSibling, otherRoot.RightSibling}
 		} else if thisRoot.RightSibling != nil && otherRoot.LeftSibling != nil {
-			newValue := NodeHash(otherRoot.LeftSibling, thisRoot.RightSibling)
+			newValue := h.NodeHash(otherRoot.LeftSibling, thisRoot.RightSibling)
 			joined[0] = AuditNode{0, 0, newValue, otherRoot.LeftSibling, thisRoot.RightSibling}
 		} else {
 			return nil, errors.New("Unexpected intersection at root")
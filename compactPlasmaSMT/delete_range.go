@@ -0,0 +1,97 @@
+package compactplasmasmt
+
+// DeleteSubtree drops every node under the given one. The returned audit set holds the
+// emptied subtree root and every ancestor up to the tree root, in the canonical order.
+func (s *CSMT) DeleteSubtree(level uint8, nodeID uint64) AuditNodes {
+	return s.deleteSubtrees([]iteratorNode{{level, nodeID}})
+}
+
+// DeleteRange drops all leaves with indexes in [from, to). The range is split into the
+// largest aligned subtrees, so a range covering full blocks is removed block by block
+// instead of leaf by leaf.
+func (s *CSMT) DeleteRange(from, to uint64) AuditNodes {
+	var subtrees []iteratorNode
+	if s.Height < 64 && to > uint64(1)<<s.Height {
+		to = uint64(1) << s.Height
+	}
+	for lo := from; lo < to; {
+		level := uint8(0)
+		for level < s.Height {
+			next := level + 1
+			if next == 64 {
+				// the range holds less than 2^64 leaves, so it never covers the whole tree
+				break
+			}
+			size := uint64(1) << next
+			if lo&(size-1) != 0 || to-lo < size {
+				break
+			}
+			level = next
+		}
+		subtrees = append(subtrees, iteratorNode{level, lo >> level})
+		lo += uint64(1) << level
+		if lo == 0 {
+			// wrapped around the full 64 bit range
+			break
+		}
+	}
+	return s.deleteSubtrees(subtrees)
+}
+
+// deleteSubtrees expects disjoint subtrees.
+func (s *CSMT) deleteSubtrees(subtrees []iteratorNode) AuditNodes {
+	var nodes AuditNodes
+	pending := make([]map[uint64]bool, int(s.Height)+1)
+	for _, subtree := range subtrees {
+		if !s.cache.Exists(subtree.level, subtree.nodeID) {
+			continue
+		}
+		s.removeSubtree(subtree.level, subtree.nodeID)
+		nodes = append(nodes, AuditNode{subtree.level, subtree.nodeID, nil, nil, nil})
+		if subtree.level < s.Height {
+			parentLevel := subtree.level + 1
+			if pending[parentLevel] == nil {
+				pending[parentLevel] = make(map[uint64]bool)
+			}
+			pending[parentLevel][subtree.nodeID>>1] = true
+		}
+	}
+	for level := uint8(1); level <= s.Height && int(level) < len(pending); level++ {
+		for nodeID := range pending[level] {
+			left := s.cache.Get(level-1, nodeID*2)
+			right := s.cache.Get(level-1, nodeID*2+1)
+			thisHash := s.cache.UpdateAndStore(level, nodeID, s.Root.nodeHash(left, right))
+			nodes = append(nodes, AuditNode{level, nodeID, thisHash, left, right})
+			if level < s.Height {
+				if pending[level+1] == nil {
+					pending[level+1] = make(map[uint64]bool)
+				}
+				pending[level+1][nodeID>>1] = true
+			}
+		}
+	}
+	// nodes are unique, so the set can always be sorted
+	canonical, _ := nodes.Canonical()
+	return canonical
+}
+
+// removeSubtree deletes all cache entries and leaves under the node, visiting only the
+// non-empty nodes.
+func (s *CSMT) removeSubtree(level uint8, nodeID uint64) {
+	stack := []iteratorNode{{level, nodeID}}
+	for len(stack) != 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !s.cache.Delete(node.level, node.nodeID) {
+			continue
+		}
+		if node.level == 0 {
+			if s.leaves != nil {
+				s.leaves.Delete(node.nodeID)
+			}
+			continue
+		}
+		stack = append(stack, iteratorNode{node.level - 1, node.nodeID * 2})
+		stack = append(stack, iteratorNode{node.level - 1, node.nodeID*2 + 1})
+	}
+}
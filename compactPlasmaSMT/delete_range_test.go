@@ -0,0 +1,105 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func deleteTestLeaves() InsertionIndexes {
+	return InsertionIndexes{
+		{UTXOIndex(1, 0, 0), []byte{0x01}},
+		{UTXOIndex(2, 0, 3), []byte{0x02}},
+		{UTXOIndex(2, 5, 0), []byte{0x03}},
+		{UTXOIndex(2, 70, 1), []byte{0x04}},
+		{UTXOIndex(3, 1, 1), []byte{0x05}},
+	}
+}
+
+func rootOf(leaves InsertionIndexes) []byte {
+	csmt := NewCSMT(treeHeight, false)
+	_ = csmt.ApplyInserts(leaves)
+	return csmt.RootHash()
+}
+
+func TestDeleteKeepsSiblings(t *testing.T) {
+	csmt := NewCSMT(4, false)
+	toInsert := make(InsertionIndexes, 2)
+	toInsert[0].Index = 0
+	toInsert[0].Value = []byte{0x01}
+	toInsert[1].Index = 1
+	toInsert[1].Value = []byte{0x02}
+	_ = csmt.ApplyInserts(toInsert)
+	path := csmt.ApplyDeletes(DeletionIndexes{0})
+	if bytes.Compare(path[0].Value, csmt.RootHash()) != 0 {
+		t.Fatal("Audit root does not match the tree")
+	}
+	single := NewCSMT(4, false)
+	_ = single.ApplyInserts(InsertionIndexes{toInsert[1]})
+	if bytes.Compare(csmt.RootHash(), single.RootHash()) != 0 {
+		t.Fatal("Sibling was lost after deletion")
+	}
+}
+
+func TestDeleteSubtree(t *testing.T) {
+	leaves := deleteTestLeaves()
+	csmt := NewCSMT(treeHeight, true)
+	_ = csmt.ApplyInserts(leaves)
+	ourIndex := leaves[4].Index
+	proof := csmt.Prove(ourIndex)
+	path := csmt.DeleteSubtree(blockLevel, 2)
+	remaining := InsertionIndexes{leaves[0], leaves[4]}
+	if bytes.Compare(csmt.RootHash(), rootOf(remaining)) != 0 {
+		t.Fatal("Root does not match the remaining leaves")
+	}
+	if len(csmt.BlockOutputs(2)) != 0 || csmt.leaves.Entries() != 2 {
+		t.Fatal("Block was not fully removed")
+	}
+	if len(path) != treeHeight-blockLevel+1 {
+		t.Fatalf("Expected a single path in audit data, got %v nodes", len(path))
+	}
+	updated, err := proof.UpdateProofImproved(ourIndex, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = updated.VefiryPath(treeHeight, ourIndex, leaves[4].Value, csmt.RootHash())
+	if err != nil {
+		t.Fatal("Updated proof did not match")
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	leaves := deleteTestLeaves()
+	csmt := NewCSMT(treeHeight, false)
+	_ = csmt.ApplyInserts(leaves)
+	path := csmt.DeleteRange(UTXOIndex(2, 4, 0), UTXOIndex(3, 1, 2))
+	remaining := InsertionIndexes{leaves[0], leaves[1]}
+	if bytes.Compare(csmt.RootHash(), rootOf(remaining)) != 0 {
+		t.Fatal("Root does not match the remaining leaves")
+	}
+	if bytes.Compare(path[0].Value, csmt.RootHash()) != 0 {
+		t.Fatal("Audit root does not match the tree")
+	}
+	if _, err := path.Commitment(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteRangeFullHeight(t *testing.T) {
+	const last = ^uint64(0)
+	leaves := InsertionIndexes{{0, []byte{0x01}}, {1 << 40, []byte{0x02}}, {last, []byte{0x03}}}
+	csmt := NewCSMT(64, false)
+	_ = csmt.ApplyInserts(leaves)
+	_ = csmt.DeleteRange(0, 1)
+	full := NewCSMT(64, false)
+	_ = full.ApplyInserts(leaves[1:])
+	if bytes.Compare(csmt.RootHash(), full.RootHash()) != 0 {
+		t.Fatal("Root does not match the remaining leaves")
+	}
+	// the exclusive end can not reach the last leaf
+	_ = csmt.DeleteRange(0, last)
+	single := NewCSMT(64, false)
+	_ = single.ApplyInserts(leaves[2:])
+	if bytes.Compare(csmt.RootHash(), single.RootHash()) != 0 {
+		t.Fatal("Range deleted the whole tree")
+	}
+}
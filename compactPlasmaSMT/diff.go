@@ -0,0 +1,75 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"errors"
+)
+
+// Clone returns an independent copy of the tree, so a version can be kept around for
+// later comparison while the original tree keeps changing. Versions share no nodes: every
+// clone is a full copy of the node cache and of the stored leaves, so it costs time and
+// memory proportional to the size of the tree.
+func (s *CSMT) Clone() *CSMT {
+	clone := NewCSMTWithHasher(s.Height, s.leaves != nil, s.Hasher())
+	for k, v := range *s.cache {
+		(*clone.cache)[k] = v
+	}
+	if s.leaves != nil {
+		for k, v := range *s.leaves {
+			clone.leaves.Insert(k, v)
+		}
+	}
+	return clone
+}
+
+// TreeDiff lists leaves that differ between two versions of the tree, in index order.
+type TreeDiff struct {
+	Added    []uint64
+	Removed  []uint64
+	Modified []uint64
+	// Audit holds the nodes of the new version that differ from the old one, in the
+	// canonical order. It is enough to update any proof from the old root to the new one.
+	Audit AuditNodes
+}
+
+// Diff compares two versions of the tree, going down only into subtrees whose hashes differ.
+// Both versions must have the same height and hasher.
+func Diff(oldVersion, newVersion *CSMT, withAudit bool) (*TreeDiff, error) {
+	if oldVersion.Height != newVersion.Height {
+		return nil, errors.New("Trees have different heights")
+	}
+	if oldVersion.Hasher().ID() != newVersion.Hasher().ID() {
+		return nil, errors.New("Trees use different hashers")
+	}
+	diff := new(TreeDiff)
+	var walk func(level uint8, nodeID uint64)
+	walk = func(level uint8, nodeID uint64) {
+		oldHash := oldVersion.cache.Get(level, nodeID)
+		newHash := newVersion.cache.Get(level, nodeID)
+		if bytes.Compare(oldHash, newHash) == 0 {
+			return
+		}
+		if withAudit {
+			node := AuditNode{level, nodeID, newHash, nil, nil}
+			if level != 0 {
+				node.LeftSibling = newVersion.cache.Get(level-1, nodeID*2)
+				node.RightSibling = newVersion.cache.Get(level-1, nodeID*2+1)
+			}
+			diff.Audit = append(diff.Audit, node)
+		}
+		if level == 0 {
+			if oldHash == nil {
+				diff.Added = append(diff.Added, nodeID)
+			} else if newHash == nil {
+				diff.Removed = append(diff.Removed, nodeID)
+			} else {
+				diff.Modified = append(diff.Modified, nodeID)
+			}
+			return
+		}
+		walk(level-1, nodeID*2)
+		walk(level-1, nodeID*2+1)
+	}
+	walk(oldVersion.Height, 0)
+	return diff, nil
+}
@@ -0,0 +1,60 @@
+package compactplasmasmt
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	csmt := NewCSMT(treeHeight, false)
+	_ = csmt.ApplyInserts(InsertionIndexes{
+		{UTXOIndex(1, 0, 0), []byte{0x01}},
+		{UTXOIndex(1, 0, 1), []byte{0x02}},
+		{UTXOIndex(4, 2, 0), []byte{0x03}},
+	})
+	oldVersion := csmt.Clone()
+	ourIndex := UTXOIndex(1, 0, 1)
+	proof := csmt.Prove(ourIndex)
+
+	_ = csmt.ApplyDeletes(DeletionIndexes{UTXOIndex(1, 0, 0)})
+	_ = csmt.ApplyInserts(InsertionIndexes{
+		{UTXOIndex(4, 2, 0), []byte{0x04}},
+		{UTXOIndex(7, 0, 0), []byte{0x05}},
+	})
+
+	diff, err := Diff(oldVersion, csmt, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0] != UTXOIndex(7, 0, 0) {
+		t.Fatalf("Unexpected added leaves %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0] != UTXOIndex(1, 0, 0) {
+		t.Fatalf("Unexpected removed leaves %v", diff.Removed)
+	}
+	if len(diff.Modified) != 1 || diff.Modified[0] != UTXOIndex(4, 2, 0) {
+		t.Fatalf("Unexpected modified leaves %v", diff.Modified)
+	}
+	updated, err := proof.UpdateProofImproved(ourIndex, diff.Audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = updated.VefiryPath(treeHeight, ourIndex, []byte{0x02}, csmt.RootHash())
+	if err != nil {
+		t.Fatal("Proof updated with the diff audit data did not match")
+	}
+
+	same, _ := Diff(csmt, csmt.Clone(), true)
+	if len(same.Added)+len(same.Removed)+len(same.Modified)+len(same.Audit) != 0 {
+		t.Fatal("Identical trees have a diff")
+	}
+}
+
+func TestDiffRejectsDifferentTrees(t *testing.T) {
+	csmt := NewCSMT(treeHeight, false)
+	if _, err := Diff(csmt, NewCSMT(treeHeight-1, false), false); err == nil {
+		t.Fatal("Trees of different heights were compared")
+	}
+	if _, err := Diff(csmt, NewCSMTWithHasher(treeHeight, false, Keccak256), false); err == nil {
+		t.Fatal("Trees with different hashers were compared")
+	}
+}
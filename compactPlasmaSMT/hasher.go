@@ -0,0 +1,108 @@
+package compactplasmasmt
+
+import (
+	"errors"
+	"hash"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Hasher is a pair of hash functions used to build the tree. Every hasher follows the same
+// compact rule: hash(null||null) = null and a node with a single child hashes only that child.
+// Every hashed value is prefixed with a tag, so a leaf, a node with only a left child, a
+// node with only a right child and a node with two children can not be mistaken for each
+// other and a path binds the side of every child.
+type Hasher interface {
+	ID() uint8
+	Name() string
+	NodeHash(left, right []byte) []byte
+	LeafHash(leaf []byte) []byte
+}
+
+var (
+	// SHA512_256 is the default hasher, it is the one used by NodeHash and LeafHash.
+	SHA512_256 Hasher = sha512Hasher{}
+	// Keccak256 matches the hashing available to Ethereum contracts.
+	Keccak256 Hasher = keccakHasher{}
+)
+
+var hashers = []Hasher{SHA512_256, Keccak256}
+
+// HasherByID returns a hasher by the ID stored in snapshots.
+func HasherByID(id uint8) (Hasher, error) {
+	for _, h := range hashers {
+		if h.ID() == id {
+			return h, nil
+		}
+	}
+	return nil, errors.New("Unsupported hasher")
+}
+
+// HasherByName returns a hasher by its name like "sha512_256" or "keccak256".
+func HasherByName(name string) (Hasher, error) {
+	for _, h := range hashers {
+		if h.Name() == name {
+			return h, nil
+		}
+	}
+	return nil, errors.New("Unsupported hasher")
+}
+
+// Tags prefixing the hashed values.
+const (
+	LeafTag  byte = 0x00
+	LeftTag  byte = 0x01 // only the left child is present
+	RightTag byte = 0x02 // only the right child is present
+	PairTag  byte = 0x03
+)
+
+func compactNodeHash(newHash func() hash.Hash, left, right []byte) []byte {
+	hasher := newHash()
+	switch {
+	case left == nil && right == nil:
+		return nil
+	case right == nil:
+		hasher.Write([]byte{LeftTag})
+	case left == nil:
+		hasher.Write([]byte{RightTag})
+	default:
+		hasher.Write([]byte{PairTag})
+	}
+	hasher.Write(left)
+	hasher.Write(right)
+	return hasher.Sum(nil)
+}
+
+func leafHash(newHash func() hash.Hash, data []byte) []byte {
+	hasher := newHash()
+	hasher.Write([]byte{LeafTag})
+	hasher.Write(data)
+	return hasher.Sum(nil)
+}
+
+// checkSibling checks that a sibling used by a path is empty or a single hash, anything
+// longer could stand for a pair of children.
+func checkSibling(h Hasher, sibling []byte) error {
+	if sibling != nil && len(sibling) != len(h.LeafHash(nil)) {
+		return errors.New("Sibling is not a hash")
+	}
+	return nil
+}
+
+type sha512Hasher struct{}
+
+func (sha512Hasher) ID() uint8                          { return 1 }
+func (sha512Hasher) Name() string                       { return "sha512_256" }
+func (sha512Hasher) NodeHash(left, right []byte) []byte { return NodeHash(left, right) }
+func (sha512Hasher) LeafHash(leaf []byte) []byte        { return LeafHash(leaf) }
+
+type keccakHasher struct{}
+
+func (keccakHasher) ID() uint8    { return 2 }
+func (keccakHasher) Name() string { return "keccak256" }
+func (keccakHasher) NodeHash(left, right []byte) []byte {
+	return compactNodeHash(sha3.NewLegacyKeccak256, left, right)
+}
+func (keccakHasher) LeafHash(leaf []byte) []byte {
+	return leafHash(sha3.NewLegacyKeccak256, leaf)
+}
@@ -0,0 +1,65 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKeccakTree(t *testing.T) {
+	csmt := NewCSMTWithHasher(8, false, Keccak256)
+	toInsert := InsertionIndexes{{3, []byte{0x01}}, {200, []byte{0x02}}}
+	path := csmt.ApplyInserts(toInsert)
+	if bytes.Compare(path[0].Value, NewCSMT(8, false).ApplyInserts(toInsert)[0].Value) == 0 {
+		t.Fatal("Hasher was not used")
+	}
+	proof := csmt.Prove(200)
+	if err := proof.VerifyPathWith(Keccak256, 8, 200, []byte{0x02}, csmt.RootHash()); err != nil {
+		t.Fatal(err)
+	}
+	if err := proof.VefiryPath(8, 200, []byte{0x02}, csmt.RootHash()); err == nil {
+		t.Fatal("Keccak proof was accepted by the default hasher")
+	}
+	if err := proof.VerifySubtreePathWith(Keccak256, 8, 0, 200, proof[8].Value, csmt.RootHash()); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	_ = csmt.ExportSnapshot(&buf)
+	imported, err := ImportSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Hasher() != Keccak256 || bytes.Compare(imported.RootHash(), csmt.RootHash()) != 0 {
+		t.Fatal("Snapshot lost the hasher")
+	}
+}
+
+func TestKeccakReferenceAndProofUpdates(t *testing.T) {
+	csmt := NewCSMTWithHasher(treeHeight, true, Keccak256)
+	ref := NewReferenceSMTWithHasher(treeHeight, Keccak256)
+	first := InsertionIndexes{{UTXOIndex(1, 0, 0), []byte{0x01}}, {UTXOIndex(1, 3, 1), []byte{0x02}}}
+	if err := CheckDifferential(csmt, ref, first, nil); err != nil {
+		t.Fatal(err)
+	}
+	applyBatch(csmt, ref, first, nil)
+	if err := CheckDifferential(csmt, NewReferenceSMT(treeHeight), nil, nil); err == nil {
+		t.Fatal("Reference with another hasher was accepted")
+	}
+	computed, err := ComputeSubtreeRootWith(Keccak256, blockLevel, 1, csmt.BlockOutputs(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(computed, csmt.BlockRoot(1)) != 0 {
+		t.Fatal("Block root does not match its outputs")
+	}
+
+	ours := first[1].Index
+	proof := csmt.Prove(ours)
+	audit := csmt.ApplyInserts(InsertionIndexes{{UTXOIndex(2, 0, 0), []byte{0x03}}})
+	updated, err := proof.UpdateProofWith(Keccak256, ours, audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := updated.VerifyPathWith(Keccak256, treeHeight, ours, first[1].Value, csmt.RootHash()); err != nil {
+		t.Fatal(err)
+	}
+}
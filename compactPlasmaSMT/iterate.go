@@ -0,0 +1,99 @@
+package compactplasmasmt
+
+// UTXOIndex packs block, transaction and output numbers into a leaf index.
+func UTXOIndex(block, transaction, output uint64) uint64 {
+	return block<<(transactionPrefixBits+outputPrefixBits) | transaction<<outputPrefixBits | output
+}
+
+// SplitUTXOIndex is an inverse of UTXOIndex.
+func SplitUTXOIndex(index uint64) (block, transaction, output uint64) {
+	block = index >> (transactionPrefixBits + outputPrefixBits)
+	transaction = (index >> outputPrefixBits) & (uint64(1)<<transactionPrefixBits - 1)
+	output = index & (uint64(1)<<outputPrefixBits - 1)
+	return block, transaction, output
+}
+
+type iteratorNode struct {
+	level  uint8
+	nodeID uint64
+}
+
+// LeafIterator walks over live leaves in index order. Only non-empty subtrees are visited,
+// so the cost depends on the number of leaves and not on the tree height.
+type LeafIterator struct {
+	tree  *CSMT
+	from  uint64
+	to    uint64
+	stack []iteratorNode
+	index uint64
+}
+
+// Iterate returns an iterator over live leaves with indexes in [from, to).
+func (s *CSMT) Iterate(from, to uint64) *LeafIterator {
+	it := &LeafIterator{tree: s, from: from, to: to}
+	if from < to && s.cache.Exists(s.Height, 0) {
+		it.stack = append(it.stack, iteratorNode{s.Height, 0})
+	}
+	return it
+}
+
+// Next moves to the next leaf, it returns false when there are no more leaves.
+func (it *LeafIterator) Next() bool {
+	for len(it.stack) != 0 {
+		node := it.stack[len(it.stack)-1]
+		it.stack = it.stack[:len(it.stack)-1]
+		first := node.nodeID << node.level
+		last := first + (uint64(1)<<node.level - 1)
+		if last < it.from || first >= it.to {
+			continue
+		}
+		if node.level == 0 {
+			it.index = node.nodeID
+			return true
+		}
+		left := iteratorNode{node.level - 1, node.nodeID * 2}
+		right := iteratorNode{node.level - 1, node.nodeID*2 + 1}
+		if it.tree.cache.Exists(right.level, right.nodeID) {
+			it.stack = append(it.stack, right)
+		}
+		if it.tree.cache.Exists(left.level, left.nodeID) {
+			it.stack = append(it.stack, left)
+		}
+	}
+	return false
+}
+
+// Index returns the index of the current leaf.
+func (it *LeafIterator) Index() uint64 {
+	return it.index
+}
+
+// Hash returns the hash of the current leaf.
+func (it *LeafIterator) Hash() []byte {
+	return it.tree.cache.Get(0, it.index)
+}
+
+// Value returns the raw value of the current leaf, nil if leaf storage is disabled.
+func (it *LeafIterator) Value() []byte {
+	return it.tree.Leaf(it.index)
+}
+
+func (s *CSMT) collect(from, to uint64) InsertionIndexes {
+	var leaves InsertionIndexes
+	it := s.Iterate(from, to)
+	for it.Next() {
+		leaves = append(leaves, InsertedIndex{it.Index(), it.Value()})
+	}
+	return leaves
+}
+
+// BlockOutputs returns all unspent outputs created in the block. Values are only set if
+// leaf storage is enabled.
+func (s *CSMT) BlockOutputs(block uint64) InsertionIndexes {
+	return s.collect(UTXOIndex(block, 0, 0), UTXOIndex(block+1, 0, 0))
+}
+
+// TransactionOutputs returns all unspent outputs created by the transaction.
+func (s *CSMT) TransactionOutputs(block, transaction uint64) InsertionIndexes {
+	return s.collect(UTXOIndex(block, transaction, 0), UTXOIndex(block, transaction+1, 0))
+}
@@ -0,0 +1,52 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIterateInOrder(t *testing.T) {
+	csmt := NewCSMT(16, true)
+	toInsert := make(InsertionIndexes, 4)
+	for i, idx := range []uint64{5, 6, 1000, 65535} {
+		toInsert[i].Index = idx
+		toInsert[i].Value = []byte{byte(i + 1)}
+	}
+	_ = csmt.ApplyInserts(toInsert)
+	it := csmt.Iterate(6, 65535)
+	var found []uint64
+	for it.Next() {
+		found = append(found, it.Index())
+	}
+	if len(found) != 2 || found[0] != 6 || found[1] != 1000 {
+		t.Fatalf("Unexpected leaves %v", found)
+	}
+	all := csmt.collect(0, 1<<16)
+	if len(all) != 4 || bytes.Compare(all[3].Value, []byte{0x04}) != 0 {
+		t.Fatal("Full range iteration failed")
+	}
+}
+
+func TestBlockOutputs(t *testing.T) {
+	csmt := NewCSMT(treeHeight, true)
+	toInsert := InsertionIndexes{
+		{UTXOIndex(1, 7, 0), []byte{0x01}},
+		{UTXOIndex(2, 0, 1), []byte{0x02}},
+		{UTXOIndex(2, 0, 15), []byte{0x03}},
+		{UTXOIndex(2, 3, 0), []byte{0x04}},
+		{UTXOIndex(3, 0, 0), []byte{0x05}},
+	}
+	_ = csmt.ApplyInserts(toInsert)
+	outputs := csmt.BlockOutputs(2)
+	if len(outputs) != 3 {
+		t.Fatalf("Expected 3 outputs in block 2, got %v", len(outputs))
+	}
+	outputs = csmt.TransactionOutputs(2, 0)
+	if len(outputs) != 2 || bytes.Compare(outputs[1].Value, []byte{0x03}) != 0 {
+		t.Fatal("Wrong outputs for transaction (2, 0)")
+	}
+	block, transaction, output := SplitUTXOIndex(outputs[1].Index)
+	if block != 2 || transaction != 0 || output != 15 {
+		t.Fatal("Index was not split correctly")
+	}
+}
@@ -0,0 +1,71 @@
+package compactplasmasmt
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// JSON form of an audit node, hashes are 0x prefixed hex and empty for null:
+//
+//	{"level": 0, "index": 5, "value": "0x..", "left": "", "right": ""}
+type auditNodeJSON struct {
+	Level uint8  `json:"level"`
+	Index uint64 `json:"index"`
+	Value string `json:"value"`
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+// EncodeHex returns the data as 0x prefixed hex, an empty string for nil. Every JSON form
+// in the project uses it for byte fields.
+func EncodeHex(data []byte) string {
+	if data == nil {
+		return ""
+	}
+	return "0x" + hex.EncodeToString(data)
+}
+
+// DecodeHex decodes EncodeHex output, an empty string is nil.
+func DecodeHex(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "0x") {
+		return nil, errors.New("Hex value must start with 0x")
+	}
+	return hex.DecodeString(s[2:])
+}
+
+func decodeHash(s string) ([]byte, error) {
+	data, err := DecodeHex(s)
+	if err != nil {
+		return nil, err
+	}
+	if s != "" && (len(data) == 0 || len(data) > 255) {
+		return nil, errors.New("Invalid hash length")
+	}
+	return data, nil
+}
+
+func (n AuditNode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(auditNodeJSON{n.Level, n.Index, EncodeHex(n.Value), EncodeHex(n.LeftSibling), EncodeHex(n.RightSibling)})
+}
+
+func (n *AuditNode) UnmarshalJSON(data []byte) error {
+	var decoded auditNodeJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	fields := make([][]byte, 3)
+	for i, s := range []string{decoded.Value, decoded.Left, decoded.Right} {
+		field, err := decodeHash(s)
+		if err != nil {
+			return err
+		}
+		fields[i] = field
+	}
+	*n = AuditNode{decoded.Level, decoded.Index, fields[0], fields[1], fields[2]}
+	return nil
+}
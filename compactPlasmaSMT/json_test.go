@@ -0,0 +1,26 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestAuditNodeJSON(t *testing.T) {
+	path := auditTestSet()
+	encoded, err := json.Marshal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded AuditNodes
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(path.Encode(), decoded.Encode()) != 0 {
+		t.Fatal("Audit set did not survive a JSON round trip")
+	}
+	var n AuditNode
+	if err := json.Unmarshal([]byte(`{"level":1,"index":2,"value":"abcd"}`), &n); err == nil {
+		t.Fatal("Value without 0x prefix was decoded")
+	}
+}
@@ -0,0 +1,24 @@
+package compactplasmasmt
+
+// LeafStorage keeps raw values of live leaves keyed by the leaf index.
+type LeafStorage map[uint64][]byte
+
+// Get returns a value of the leaf, nil if the leaf is empty.
+func (l LeafStorage) Get(index uint64) []byte {
+	return l[index]
+}
+
+func (l LeafStorage) Insert(index uint64, value []byte) {
+	l[index] = value
+}
+
+func (l LeafStorage) Delete(index uint64) bool {
+	_, exists := l[index]
+	delete(l, index)
+	return exists
+}
+
+// Entries returns the number of stored leaves.
+func (l LeafStorage) Entries() int {
+	return len(l)
+}
@@ -0,0 +1,159 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// Plain binary Merkle tree over an ordered list of items, used to commit to lists like the
+// block's audit set or transactions. The tree is shaped like in RFC 6962: the left subtree
+// always holds the largest power of two items, leaves and nodes are hashed with different
+// prefixes, and the root additionally commits to the number of items.
+
+const (
+	listLeafPrefix = byte(0x00)
+	listNodePrefix = byte(0x01)
+	listRootPrefix = byte(0x02)
+)
+
+func listLeafHash(item []byte) []byte {
+	hasher := sha512.New512_256()
+	hasher.Write([]byte{listLeafPrefix})
+	hasher.Write(item)
+	return hasher.Sum(nil)
+}
+
+func listNodeHash(left, right []byte) []byte {
+	hasher := sha512.New512_256()
+	hasher.Write([]byte{listNodePrefix})
+	hasher.Write(left)
+	hasher.Write(right)
+	return hasher.Sum(nil)
+}
+
+func listFinalHash(total int, root []byte) []byte {
+	hasher := sha512.New512_256()
+	hasher.Write([]byte{listRootPrefix})
+	count := make([]byte, 8)
+	binary.BigEndian.PutUint64(count, uint64(total))
+	hasher.Write(count)
+	hasher.Write(root)
+	return hasher.Sum(nil)
+}
+
+// listSplit returns the largest power of two that is less than n, n must be at least 2.
+func listSplit(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+func listSubtreeRoot(items [][]byte) []byte {
+	if len(items) == 1 {
+		return listLeafHash(items[0])
+	}
+	k := listSplit(len(items))
+	return listNodeHash(listSubtreeRoot(items[:k]), listSubtreeRoot(items[k:]))
+}
+
+// ListRoot returns a commitment to the ordered list of items, nil for an empty list.
+func ListRoot(items [][]byte) []byte {
+	if len(items) == 0 {
+		return nil
+	}
+	return listFinalHash(len(items), listSubtreeRoot(items))
+}
+
+// positionsIn returns the range of sorted positions that fall into [lo, hi).
+func positionsIn(positions []int, lo, hi int) (int, int) {
+	return sort.SearchInts(positions, lo), sort.SearchInts(positions, hi)
+}
+
+func checkPositions(total int, positions []int) error {
+	if len(positions) == 0 {
+		return errors.New("No positions to prove")
+	}
+	for i, p := range positions {
+		if p < 0 || p >= total || (i > 0 && positions[i-1] >= p) {
+			return errors.New("Positions must be sorted, unique and within the list")
+		}
+	}
+	return nil
+}
+
+// ListProof returns the hashes that are required to authenticate the items at the sorted
+// positions. Neighbouring positions share the proof, so proving a whole range is cheap.
+func ListProof(items [][]byte, positions []int) ([][]byte, error) {
+	if err := checkPositions(len(items), positions); err != nil {
+		return nil, err
+	}
+	var proof [][]byte
+	var walk func(lo, hi int)
+	walk = func(lo, hi int) {
+		first, last := positionsIn(positions, lo, hi)
+		if first == last {
+			proof = append(proof, listSubtreeRoot(items[lo:hi]))
+			return
+		}
+		if last-first == hi-lo {
+			return
+		}
+		k := listSplit(hi - lo)
+		walk(lo, lo+k)
+		walk(lo+k, hi)
+	}
+	walk(0, len(items))
+	return proof, nil
+}
+
+// VerifyListProof checks that items are at the given sorted positions of a list of total
+// items committed by the root.
+func VerifyListProof(root []byte, total int, positions []int, items, proof [][]byte) error {
+	if len(items) != len(positions) {
+		return errors.New("Number of items does not match the positions")
+	}
+	if err := checkPositions(total, positions); err != nil {
+		return err
+	}
+	var walk func(lo, hi int) ([]byte, error)
+	walk = func(lo, hi int) ([]byte, error) {
+		first, last := positionsIn(positions, lo, hi)
+		if first == last {
+			if len(proof) == 0 {
+				return nil, errors.New("Proof is too short")
+			}
+			hash := proof[0]
+			proof = proof[1:]
+			return hash, nil
+		}
+		if last-first == hi-lo {
+			return listSubtreeRoot(items[first:last]), nil
+		}
+		k := listSplit(hi - lo)
+		left, err := walk(lo, lo+k)
+		if err != nil {
+			return nil, err
+		}
+		right, err := walk(lo+k, hi)
+		if err != nil {
+			return nil, err
+		}
+		return listNodeHash(left, right), nil
+	}
+	subtreeRoot, err := walk(0, total)
+	if err != nil {
+		return err
+	}
+	if len(proof) != 0 {
+		return errors.New("Proof is too long")
+	}
+	if bytes.Compare(listFinalHash(total, subtreeRoot), root) != 0 {
+		return errors.New("List root does not match")
+	}
+	return nil
+}
@@ -59,16 +59,11 @@ func TestKindOfPlasma(t *testing.T) {
 	}
 	// SORT! insersion indexes
 	sort.Sort(&toInsert)
-	now := time.Now()
 	path := csmt.ApplyInserts(toInsert)
-	elapsed := time.Since(now)
-	log.Printf("Insertion of %v entities for tree of height %v has taken %v ms", len(toInsert), totalPlasmaHeight, float64(elapsed.Nanoseconds())/float64(1000000.0))
-	log.Printf("Modeled TPS = %v", float64(len(toInsert))/float64(elapsed.Nanoseconds())*float64(1000000000))
 	randInt := rand.Intn(len(toInsert))
 	ourOutput := toInsert[randInt]
 	log.Printf("Our output is number %v", ourOutput.Index)
 	filtered := path.FilterPath(uint8(totalPlasmaHeight), ourOutput.Index)
-	log.Printf("Total auxilary block information for proof updates will be roughtly %v kB", len(path)*33/1024)
 
 	log.Println("Producing block 2")
 	blockNumber = uint64(2) << blockNumberLength
@@ -90,13 +85,7 @@ func TestKindOfPlasma(t *testing.T) {
 		allIndexes[indexNumber] = true
 	}
 	sort.Sort(&toInsert)
-	now = time.Now()
 	path2 := csmt.ApplyInserts(toInsert)
-	elapsed = time.Since(now)
-	log.Printf("Insertion of %v entities for tree of height %v has taken %v ms", len(toInsert), totalPlasmaHeight, float64(elapsed.Nanoseconds())/float64(1000000.0))
-	log.Printf("Modeled TPS = %v", float64(len(toInsert))/float64(elapsed.Nanoseconds())*float64(1000000000))
-
-	log.Printf("Total auxilary block information for proof updates will be roughtly %v kB", len(path2)*33/1024)
 
 	joined, err := filtered.UpdateProofImproved(ourOutput.Index, path2)
 	if err != nil {
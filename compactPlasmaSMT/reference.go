@@ -0,0 +1,194 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ReferenceSMT is a naive sparse Merkle tree: it keeps only the leaves and recomputes every
+// level from them using the same hasher as the tree it is compared with. It is slow, but
+// simple enough to be obviously correct, so the compact recursive algorithms can be checked
+// against it.
+type ReferenceSMT struct {
+	Height uint8
+	Leaves map[uint64][]byte
+	hasher Hasher
+}
+
+func NewReferenceSMT(height uint8) *ReferenceSMT {
+	return NewReferenceSMTWithHasher(height, SHA512_256)
+}
+
+// NewReferenceSMTWithHasher creates an empty reference for a tree built with the hasher.
+func NewReferenceSMTWithHasher(height uint8, hasher Hasher) *ReferenceSMT {
+	return &ReferenceSMT{height, make(map[uint64][]byte), hasher}
+}
+
+func (r *ReferenceSMT) Insert(index uint64, value []byte) {
+	r.Leaves[index] = value
+}
+
+func (r *ReferenceSMT) Delete(index uint64) {
+	delete(r.Leaves, index)
+}
+
+func (r *ReferenceSMT) clone() *ReferenceSMT {
+	clone := NewReferenceSMTWithHasher(r.Height, r.hasher)
+	for index, value := range r.Leaves {
+		clone.Leaves[index] = value
+	}
+	return clone
+}
+
+// levels returns all non-empty nodes, levels[0] holds the leaf hashes.
+func (r *ReferenceSMT) levels() []map[uint64][]byte {
+	levels := make([]map[uint64][]byte, int(r.Height)+1)
+	levels[0] = make(map[uint64][]byte)
+	for index, value := range r.Leaves {
+		levels[0][index] = r.hasher.LeafHash(value)
+	}
+	for l := 1; l <= int(r.Height); l++ {
+		levels[l] = make(map[uint64][]byte)
+		for child := range levels[l-1] {
+			parent := child / 2
+			levels[l][parent] = r.hasher.NodeHash(levels[l-1][parent*2], levels[l-1][parent*2+1])
+		}
+	}
+	return levels
+}
+
+// Root returns the root of the tree, nil for an empty tree.
+func (r *ReferenceSMT) Root() []byte {
+	return r.levels()[r.Height][0]
+}
+
+// Node returns the hash of the node at a given level.
+func (r *ReferenceSMT) Node(level uint8, nodeID uint64) []byte {
+	return r.levels()[level][nodeID]
+}
+
+// Prove returns a path in the same form as CSMT.Prove does.
+func (r *ReferenceSMT) Prove(index uint64) AuditNodes {
+	return r.prove(r.levels(), index)
+}
+
+func (r *ReferenceSMT) prove(levels []map[uint64][]byte, index uint64) AuditNodes {
+	path := make(AuditNodes, int(r.Height)+1)
+	for i := range path {
+		level := r.Height - uint8(i)
+		nodeID := index >> level
+		path[i] = AuditNode{level, nodeID, levels[level][nodeID], nil, nil}
+		if level != 0 {
+			path[i].LeftSibling = levels[level-1][nodeID*2]
+			path[i].RightSibling = levels[level-1][nodeID*2+1]
+		}
+	}
+	return path
+}
+
+func compareAuditNode(expected, actual AuditNode) error {
+	if expected.Level != actual.Level || expected.Index != actual.Index {
+		return fmt.Errorf("Expected node %v at level %v, got node %v at level %v", expected.Index, expected.Level, actual.Index, actual.Level)
+	}
+	if bytes.Compare(expected.Value, actual.Value) != 0 ||
+		bytes.Compare(expected.LeftSibling, actual.LeftSibling) != 0 ||
+		bytes.Compare(expected.RightSibling, actual.RightSibling) != 0 {
+		return fmt.Errorf("Node %v at level %v does not match the reference", actual.Index, actual.Level)
+	}
+	return nil
+}
+
+// checkAuditNodes checks every node of the audit set against the reference state.
+func (r *ReferenceSMT) checkAuditNodes(nodes AuditNodes) error {
+	levels := r.levels()
+	for _, n := range nodes {
+		if int(n.Level) > int(r.Height) {
+			return errors.New("Audit node is above the root")
+		}
+		expected := AuditNode{n.Level, n.Index, levels[n.Level][n.Index], nil, nil}
+		if n.Level != 0 {
+			expected.LeftSibling = levels[n.Level-1][n.Index*2]
+			expected.RightSibling = levels[n.Level-1][n.Index*2+1]
+		}
+		if err := compareAuditNode(expected, n); err != nil {
+			return err
+		}
+	}
+	if _, err := nodes.Canonical(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CheckDifferential applies the same batch to the tree and to the reference and compares
+// the roots, every returned audit node and the proofs for all touched leaves. Deletes are
+// applied first, like the operator does when spending inputs and creating outputs. It can be
+// used in randomized tests as well as for spot checks of a production tree against a
+// reference built from its leaves.
+//
+// The batch is applied to a Clone of the tree and a copy of the reference, so neither of
+// them is changed whatever the result. The caller applies the batch once the check passes.
+func CheckDifferential(tree *CSMT, ref *ReferenceSMT, inserts InsertionIndexes, deletes DeletionIndexes) error {
+	if tree.Height != ref.Height {
+		return errors.New("Trees have different heights")
+	}
+	if tree.Hasher() != ref.hasher {
+		return errors.New("Trees use different hashers")
+	}
+	if bytes.Compare(tree.RootHash(), ref.Root()) != 0 {
+		return errors.New("Roots differ before applying the batch")
+	}
+	tree, ref = tree.Clone(), ref.clone()
+	sortedDeletes := append(DeletionIndexes{}, deletes...)
+	sort.Sort(sortedDeletes)
+	sortedInserts := append(InsertionIndexes{}, inserts...)
+	sort.Sort(sortedInserts)
+
+	if len(sortedDeletes) != 0 {
+		deleted := tree.ApplyDeletes(sortedDeletes)
+		for _, index := range sortedDeletes {
+			ref.Delete(index)
+		}
+		if err := ref.checkAuditNodes(deleted); err != nil {
+			return fmt.Errorf("Deletion: %v", err)
+		}
+	}
+	if len(sortedInserts) != 0 {
+		inserted := tree.ApplyInserts(sortedInserts)
+		for _, leaf := range sortedInserts {
+			ref.Insert(leaf.Index, leaf.Value)
+		}
+		if err := ref.checkAuditNodes(inserted); err != nil {
+			return fmt.Errorf("Insertion: %v", err)
+		}
+	}
+
+	root := ref.Root()
+	if bytes.Compare(tree.RootHash(), root) != 0 {
+		return errors.New("Roots differ after applying the batch")
+	}
+	levels := ref.levels()
+	touched := make([]uint64, 0, len(sortedDeletes)+len(sortedInserts))
+	touched = append(touched, sortedDeletes...)
+	for _, leaf := range sortedInserts {
+		touched = append(touched, leaf.Index)
+	}
+	for _, index := range touched {
+		actual := tree.Prove(index)
+		expected := ref.prove(levels, index)
+		for i := range expected {
+			if err := compareAuditNode(expected[i], actual[i]); err != nil {
+				return fmt.Errorf("Proof for leaf %v: %v", index, err)
+			}
+		}
+		value, live := ref.Leaves[index]
+		if live {
+			if err := actual.VerifyPathWith(ref.hasher, tree.Height, index, value, root); err != nil {
+				return fmt.Errorf("Proof for leaf %v: %v", index, err)
+			}
+		}
+	}
+	return nil
+}
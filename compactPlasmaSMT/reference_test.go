@@ -0,0 +1,79 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestReferenceMatchesSimpleTree(t *testing.T) {
+	ref := NewReferenceSMT(4)
+	ref.Insert(0, []byte{0x01})
+	valueHash := LeafHash([]byte{0x01})
+	for i := 0; i < 4; i++ {
+		valueHash = NodeHash(valueHash, nil)
+	}
+	if bytes.Compare(ref.Root(), valueHash) != 0 {
+		t.Fatal("Reference root for a single leaf is wrong")
+	}
+	if err := ref.Prove(0).VefiryPath(4, 0, []byte{0x01}, valueHash); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDifferentialRandomBatches(t *testing.T) {
+	const height = 12
+	rnd := rand.New(rand.NewSource(42))
+	tree := NewCSMT(height, true)
+	ref := NewReferenceSMT(height)
+	for round := 0; round < 30; round++ {
+		var deletes DeletionIndexes
+		for index := range ref.Leaves {
+			if rnd.Intn(3) == 0 {
+				deletes = append(deletes, index)
+			}
+		}
+		deleted := make(map[uint64]bool)
+		for _, index := range deletes {
+			deleted[index] = true
+		}
+		var inserts InsertionIndexes
+		seen := make(map[uint64]bool)
+		for i := 0; i < 20; i++ {
+			index := uint64(rnd.Intn(1 << height))
+			if seen[index] || deleted[index] {
+				continue
+			}
+			seen[index] = true
+			inserts = append(inserts, InsertedIndex{index, []byte{byte(round), byte(i)}})
+		}
+		root := tree.RootHash()
+		if err := CheckDifferential(tree, ref, inserts, deletes); err != nil {
+			t.Fatalf("Round %v: %v", round, err)
+		}
+		if bytes.Compare(tree.RootHash(), root) != 0 || bytes.Compare(ref.Root(), root) != 0 {
+			t.Fatal("Check changed the tree or the reference")
+		}
+		applyBatch(tree, ref, inserts, deletes)
+	}
+}
+
+func applyBatch(tree *CSMT, ref *ReferenceSMT, inserts InsertionIndexes, deletes DeletionIndexes) {
+	sortedDeletes := append(DeletionIndexes{}, deletes...)
+	sort.Sort(sortedDeletes)
+	sortedInserts := append(InsertionIndexes{}, inserts...)
+	sort.Sort(sortedInserts)
+	if len(sortedDeletes) != 0 {
+		tree.ApplyDeletes(sortedDeletes)
+	}
+	if len(sortedInserts) != 0 {
+		tree.ApplyInserts(sortedInserts)
+	}
+	for _, index := range deletes {
+		ref.Delete(index)
+	}
+	for _, leaf := range inserts {
+		ref.Insert(leaf.Index, leaf.Value)
+	}
+}
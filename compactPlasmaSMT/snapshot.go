@@ -0,0 +1,236 @@
+package compactplasmasmt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+)
+
+const (
+	snapshotMagic     = "CSMTSNAP"
+	snapshotVersion   = uint8(2) // version 1 trees were hashed without tags
+	snapshotHasLeaves = uint8(1)
+)
+
+// Snapshot layout, all integers are big endian:
+//
+//	magic "CSMTSNAP" | version | height | hasher ID | flags | root length | root
+//	number of cache entries (8 bytes) | number of leaves (8 bytes)
+//	cache entries: height | node ID (8 bytes) | value length | value
+//	leaves: index (8 bytes) | value length (4 bytes) | value
+//	SHA512/256 of everything above
+//
+// Entries are written in a deterministic order, so the same tree always gives the same snapshot.
+
+// ExportSnapshot streams the full state of the tree to the writer.
+func (s *CSMT) ExportSnapshot(w io.Writer) error {
+	checksum := sha512.New512_256()
+	buffered := bufio.NewWriter(w)
+	out := io.MultiWriter(buffered, checksum)
+
+	root := s.RootHash()
+	flags := uint8(0)
+	numLeaves := 0
+	if s.leaves != nil {
+		flags |= snapshotHasLeaves
+		numLeaves = s.leaves.Entries()
+	}
+	header := []byte(snapshotMagic)
+	header = append(header, snapshotVersion, s.Height, s.Hasher().ID(), flags, uint8(len(root)))
+	header = append(header, root...)
+	header = appendUint64(header, uint64(s.cache.Entries()))
+	header = appendUint64(header, uint64(numLeaves))
+	if _, err := out.Write(header); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, s.cache.Entries())
+	for k := range *s.cache {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value := (*s.cache)[k]
+		if len(value) > 255 {
+			return errors.New("Cache entry is too long")
+		}
+		entry := append([]byte(k), uint8(len(value)))
+		entry = append(entry, value...)
+		if _, err := out.Write(entry); err != nil {
+			return err
+		}
+	}
+
+	if s.leaves != nil {
+		indexes := make([]uint64, 0, numLeaves)
+		for idx := range *s.leaves {
+			indexes = append(indexes, idx)
+		}
+		sort.Sort(DeletionIndexes(indexes))
+		for _, idx := range indexes {
+			value := s.leaves.Get(idx)
+			entry := appendUint64(nil, idx)
+			entry = appendUint32(entry, uint32(len(value)))
+			entry = append(entry, value...)
+			if _, err := out.Write(entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := buffered.Write(checksum.Sum(nil)); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// ImportSnapshot reads a snapshot produced by ExportSnapshot. The tree is rebuilt from the
+// bottom level of the cache, and the snapshot is rejected if any stored node or the root
+// in the header do not match the recomputed ones.
+func ImportSnapshot(r io.Reader) (*CSMT, error) {
+	checksum := sha512.New512_256()
+	buffered := bufio.NewReader(r)
+	in := io.TeeReader(buffered, checksum)
+
+	fixed := make([]byte, len(snapshotMagic)+5)
+	if _, err := io.ReadFull(in, fixed); err != nil {
+		return nil, err
+	}
+	if string(fixed[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, errors.New("Not a tree snapshot")
+	}
+	fixed = fixed[len(snapshotMagic):]
+	if fixed[0] != snapshotVersion {
+		return nil, errors.New("Unsupported snapshot version")
+	}
+	height := fixed[1]
+	if height == 0 || height > 64 {
+		return nil, errors.New("Invalid tree height")
+	}
+	hasher, err := HasherByID(fixed[2])
+	if err != nil {
+		return nil, err
+	}
+	flags := fixed[3]
+	root := make([]byte, fixed[4])
+	if _, err := io.ReadFull(in, root); err != nil {
+		return nil, err
+	}
+	counts := make([]byte, 16)
+	if _, err := io.ReadFull(in, counts); err != nil {
+		return nil, err
+	}
+	numEntries := binary.BigEndian.Uint64(counts[:8])
+	numLeaves := binary.BigEndian.Uint64(counts[8:])
+	if flags&snapshotHasLeaves == 0 && numLeaves != 0 {
+		return nil, errors.New("Leaves are present in a snapshot without leaf storage")
+	}
+
+	imported := make(CacheBranch)
+	bottom := make(map[uint64][]byte)
+	entryHeader := make([]byte, 10)
+	for i := uint64(0); i < numEntries; i++ {
+		if _, err := io.ReadFull(in, entryHeader); err != nil {
+			return nil, err
+		}
+		level, nodeID := parseCacheKey(string(entryHeader[:9]))
+		if level > height || (height-level < 64 && nodeID>>(height-level) != 0) {
+			return nil, errors.New("Cache entry is out of the tree")
+		}
+		if entryHeader[9] == 0 {
+			return nil, errors.New("Empty cache entry")
+		}
+		value := make([]byte, entryHeader[9])
+		if _, err := io.ReadFull(in, value); err != nil {
+			return nil, err
+		}
+		if imported.Exists(level, nodeID) {
+			return nil, errors.New("Duplicate cache entry")
+		}
+		imported.Insert(level, nodeID, value)
+		if level == 0 {
+			bottom[nodeID] = value
+		}
+	}
+
+	tree := NewCSMTWithHasher(height, flags&snapshotHasLeaves != 0, hasher)
+	leafHeader := make([]byte, 12)
+	for i := uint64(0); i < numLeaves; i++ {
+		if _, err := io.ReadFull(in, leafHeader); err != nil {
+			return nil, err
+		}
+		index := binary.BigEndian.Uint64(leafHeader[:8])
+		value := make([]byte, binary.BigEndian.Uint32(leafHeader[8:]))
+		if _, err := io.ReadFull(in, value); err != nil {
+			return nil, err
+		}
+		if bytes.Compare(hasher.LeafHash(value), bottom[index]) != 0 {
+			return nil, errors.New("Leaf does not match its hash in the cache")
+		}
+		tree.leaves.Insert(index, value)
+	}
+	if tree.leaves != nil && uint64(tree.leaves.Entries()) != uint64(len(bottom)) {
+		return nil, errors.New("Number of leaves does not match the cache")
+	}
+
+	expected := checksum.Sum(nil)
+	trailer := make([]byte, len(expected))
+	if _, err := io.ReadFull(buffered, trailer); err != nil {
+		return nil, err
+	}
+	if bytes.Compare(trailer, expected) != 0 {
+		return nil, errors.New("Snapshot checksum mismatch")
+	}
+
+	rebuildCache(*tree.cache, hasher, height, bottom)
+	if tree.cache.Entries() != imported.Entries() {
+		return nil, errors.New("Snapshot cache does not match the rebuilt tree")
+	}
+	for k, v := range imported {
+		if bytes.Compare((*tree.cache)[k], v) != 0 {
+			return nil, errors.New("Snapshot cache does not match the rebuilt tree")
+		}
+	}
+	if bytes.Compare(tree.RootHash(), root) != 0 {
+		return nil, errors.New("Recomputed root does not match the snapshot")
+	}
+	return tree, nil
+}
+
+// rebuildCache fills the cache with every non-empty node computed from the bottom level hashes.
+func rebuildCache(c CacheBranch, hasher Hasher, height uint8, bottom map[uint64][]byte) {
+	current := make(map[uint64][]byte, len(bottom))
+	for idx, value := range bottom {
+		c.Insert(0, idx, value)
+		current[idx] = value
+	}
+	for level := uint8(1); level <= height; level++ {
+		next := make(map[uint64][]byte, len(current)/2+1)
+		for idx := range current {
+			parent := idx >> 1
+			if _, done := next[parent]; done {
+				continue
+			}
+			hash := hasher.NodeHash(current[parent*2], current[parent*2+1])
+			next[parent] = hash
+			c.UpdateAndStore(level, parent, hash)
+		}
+		current = next
+	}
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, v)
+	return append(b, buf...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, v)
+	return append(b, buf...)
+}
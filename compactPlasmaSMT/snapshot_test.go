@@ -0,0 +1,75 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"testing"
+)
+
+func snapshotTestTree() *CSMT {
+	csmt := NewCSMT(8, true)
+	toInsert := make(InsertionIndexes, 3)
+	toInsert[0].Index = 1
+	toInsert[0].Value = []byte{0x01}
+	toInsert[1].Index = 77
+	toInsert[1].Value = []byte{0x02}
+	toInsert[2].Index = 200
+	toInsert[2].Value = []byte{0x03}
+	_ = csmt.ApplyInserts(toInsert)
+	return csmt
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	csmt := snapshotTestTree()
+	var buf bytes.Buffer
+	err := csmt.ExportSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ImportSnapshot(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(imported.RootHash(), csmt.RootHash()) != 0 {
+		t.Fatal("Imported root does not match")
+	}
+	if imported.cache.Entries() != csmt.cache.Entries() {
+		t.Fatal("Imported cache size does not match")
+	}
+	if bytes.Compare(imported.Leaf(77), []byte{0x02}) != 0 {
+		t.Fatal("Leaf was not imported")
+	}
+	var again bytes.Buffer
+	_ = imported.ExportSnapshot(&again)
+	if bytes.Compare(buf.Bytes(), again.Bytes()) != 0 {
+		t.Fatal("Snapshot is not deterministic")
+	}
+}
+
+func TestSnapshotRejectsCorruption(t *testing.T) {
+	csmt := snapshotTestTree()
+	var buf bytes.Buffer
+	_ = csmt.ExportSnapshot(&buf)
+	corrupted := append([]byte{}, buf.Bytes()...)
+	corrupted[len(corrupted)-40] ^= 0xff
+	_, err := ImportSnapshot(bytes.NewReader(corrupted))
+	if err == nil {
+		t.Fatal("Corrupted snapshot was accepted")
+	}
+}
+
+func TestSnapshotRejectsWrongRoot(t *testing.T) {
+	csmt := snapshotTestTree()
+	var buf bytes.Buffer
+	_ = csmt.ExportSnapshot(&buf)
+	tampered := append([]byte{}, buf.Bytes()...)
+	// root starts right after magic, version, height, hasher, flags and root length
+	tampered[len(snapshotMagic)+5] ^= 0xff
+	body := tampered[:len(tampered)-32]
+	checksum := sha512.Sum512_256(body)
+	tampered = append(body, checksum[:]...)
+	_, err := ImportSnapshot(bytes.NewReader(tampered))
+	if err == nil {
+		t.Fatal("Snapshot with a wrong root was accepted")
+	}
+}
@@ -0,0 +1,133 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"errors"
+)
+
+// blockLevel is the level of per-block subtrees, the top bits of the index are the block number.
+const blockLevel = transactionPrefixBits + outputPrefixBits
+
+// SubtreeRoot returns the hash of the node at a given level, nil for an empty subtree.
+func (s *CSMT) SubtreeRoot(level uint8, nodeID uint64) []byte {
+	return s.cache.Get(level, nodeID)
+}
+
+// BlockRoot returns the root of the subtree holding all unspent outputs of the block.
+func (s *CSMT) BlockRoot(block uint64) []byte {
+	return s.SubtreeRoot(blockLevel, block)
+}
+
+// ProveNode returns a path from the root down to the node, in the same form as FilterPath
+// returns it: the root goes first and the node itself goes last. It returns nil for a node
+// outside of the tree.
+func (s *CSMT) ProveNode(level uint8, nodeID uint64) AuditNodes {
+	if level > s.Height || nodeID>>(s.Height-level) != 0 {
+		return nil
+	}
+	path := make(AuditNodes, s.Height-level+1)
+	for i := range path {
+		l := s.Height - uint8(i)
+		id := nodeID >> (l - level)
+		path[i] = AuditNode{l, id, s.cache.Get(l, id), nil, nil}
+		if l != 0 {
+			path[i].LeftSibling = s.cache.Get(l-1, id*2)
+			path[i].RightSibling = s.cache.Get(l-1, id*2+1)
+		}
+	}
+	return path
+}
+
+// Prove returns a path for the leaf that can be checked with VefiryPath.
+func (s *CSMT) Prove(index uint64) AuditNodes {
+	return s.ProveNode(0, index)
+}
+
+// ProveBlock returns a path linking the block subtree root to the global root.
+func (s *CSMT) ProveBlock(block uint64) AuditNodes {
+	return s.ProveNode(blockLevel, block)
+}
+
+// VerifySubtreePath checks that the subtree root is a node at a given level of a tree with
+// the given root. For the block subtrees use level 24 (transaction and output bits).
+func (p AuditNodes) VerifySubtreePath(height, level uint8, nodeID uint64, subtreeRoot, root []byte) error {
+	return p.VerifySubtreePathWith(SHA512_256, height, level, nodeID, subtreeRoot, root)
+}
+
+// VerifySubtreePathWith is VerifySubtreePath for a tree built with the given hasher.
+func (p AuditNodes) VerifySubtreePathWith(h Hasher, height, level uint8, nodeID uint64, subtreeRoot, root []byte) error {
+	if level > height {
+		return errors.New("Subtree is higher than the tree")
+	}
+	if len(p) != int(height-level)+1 {
+		return errors.New("Path length is invalid")
+	}
+	if bytes.Compare(p[0].Value, root) != 0 {
+		return errors.New("Root hash does not match")
+	}
+	last := p[len(p)-1]
+	if last.Level != level || last.Index != nodeID {
+		return errors.New("Most likely checking for invalid node")
+	}
+	if bytes.Compare(last.Value, subtreeRoot) != 0 {
+		return errors.New("Subtree root does not match")
+	}
+	hash := subtreeRoot
+	idx := nodeID
+	for i := len(p) - 2; i >= 0; i-- {
+		sibling := p[i].RightSibling
+		if idx&1 == 1 {
+			sibling = p[i].LeftSibling
+		}
+		if err := checkSibling(h, sibling); err != nil {
+			return err
+		}
+		if idx&1 == 0 {
+			hash = h.NodeHash(hash, sibling)
+		} else {
+			hash = h.NodeHash(sibling, hash)
+		}
+		idx = idx / 2
+	}
+	if bytes.Compare(root, hash) != 0 {
+		return errors.New("Audit path failed")
+	}
+	return nil
+}
+
+// VerifyAbsence checks that the leaf is empty in a tree with the given root, the path is
+// the one Prove returns for an empty leaf.
+func (p AuditNodes) VerifyAbsence(height uint8, index uint64, root []byte) error {
+	return p.VerifyAbsenceWith(SHA512_256, height, index, root)
+}
+
+// VerifyAbsenceWith is VerifyAbsence for a tree built with the given hasher.
+func (p AuditNodes) VerifyAbsenceWith(h Hasher, height uint8, index uint64, root []byte) error {
+	if root == nil {
+		return nil
+	}
+	return p.VerifySubtreePathWith(h, height, 0, index, nil, root)
+}
+
+// ComputeSubtreeRoot computes the root of the subtree from the full list of its leaves, so
+// a client can check a list of unspent outputs of a block against the block root.
+func ComputeSubtreeRoot(level uint8, nodeID uint64, leaves InsertionIndexes) ([]byte, error) {
+	return ComputeSubtreeRootWith(SHA512_256, level, nodeID, leaves)
+}
+
+// ComputeSubtreeRootWith is ComputeSubtreeRoot for a tree built with the given hasher.
+func ComputeSubtreeRootWith(h Hasher, level uint8, nodeID uint64, leaves InsertionIndexes) ([]byte, error) {
+	bottom := make(map[uint64][]byte, len(leaves))
+	for _, leaf := range leaves {
+		if leaf.Index>>level != nodeID {
+			return nil, errors.New("Leaf is outside of the subtree")
+		}
+		if _, exists := bottom[leaf.Index]; exists {
+			return nil, errors.New("Duplicate leaf")
+		}
+		bottom[leaf.Index] = h.LeafHash(leaf.Value)
+	}
+	c := make(CacheBranch)
+	rebuildCache(c, h, level, bottom)
+	return c.Get(level, nodeID), nil
+}
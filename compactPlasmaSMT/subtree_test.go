@@ -0,0 +1,125 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func subtreeTestTree() *CSMT {
+	csmt := NewCSMT(treeHeight, true)
+	toInsert := InsertionIndexes{
+		{UTXOIndex(1, 0, 0), []byte{0x01}},
+		{UTXOIndex(5, 2, 1), []byte{0x02}},
+		{UTXOIndex(5, 9, 0), []byte{0x03}},
+		{UTXOIndex(9, 1, 3), []byte{0x04}},
+	}
+	_ = csmt.ApplyInserts(toInsert)
+	return csmt
+}
+
+func TestProveLeaf(t *testing.T) {
+	csmt := subtreeTestTree()
+	index := UTXOIndex(5, 9, 0)
+	path := csmt.Prove(index)
+	err := path.VefiryPath(treeHeight, index, []byte{0x03}, csmt.RootHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlockRootProof(t *testing.T) {
+	csmt := subtreeTestTree()
+	blockRoot := csmt.BlockRoot(5)
+	if blockRoot == nil {
+		t.Fatal("Block root is empty")
+	}
+	path := csmt.ProveBlock(5)
+	if len(path) != treeHeight-blockLevel+1 {
+		t.Fatal("Block proof has unexpected length")
+	}
+	err := path.VerifySubtreePath(treeHeight, blockLevel, 5, blockRoot, csmt.RootHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	computed, err := ComputeSubtreeRoot(blockLevel, 5, csmt.BlockOutputs(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(computed, blockRoot) != 0 {
+		t.Fatal("Block root does not match its outputs")
+	}
+	err = path.VerifySubtreePath(treeHeight, blockLevel, 5, csmt.BlockRoot(9), csmt.RootHash())
+	if err == nil {
+		t.Fatal("Proof was accepted for a wrong block root")
+	}
+}
+
+func TestVerifyAbsence(t *testing.T) {
+	csmt := subtreeTestTree()
+	absent := UTXOIndex(5, 3, 0)
+	if err := csmt.Prove(absent).VerifyAbsence(treeHeight, absent, csmt.RootHash()); err != nil {
+		t.Fatal(err)
+	}
+	present := UTXOIndex(5, 9, 0)
+	if err := csmt.Prove(present).VerifyAbsence(treeHeight, present, csmt.RootHash()); err == nil {
+		t.Fatal("Absence was proven for a present leaf")
+	}
+	if err := csmt.Prove(absent).VerifyAbsence(treeHeight, present, csmt.RootHash()); err == nil {
+		t.Fatal("Absence path was accepted for another leaf")
+	}
+}
+
+func TestVerifyAbsenceForgery(t *testing.T) {
+	csmt := subtreeTestTree()
+	root := csmt.RootHash()
+
+	// the leaf's own hash as the sibling on the other side of a single child node
+	present := UTXOIndex(1, 0, 0)
+	swapped := append(AuditNodes{}, csmt.Prove(present)...)
+	swapped[treeHeight].Value = nil
+	swapped[treeHeight-1].LeftSibling, swapped[treeHeight-1].RightSibling = nil, LeafHash([]byte{0x01})
+	if err := swapped.VerifyAbsence(treeHeight, present, root); err == nil {
+		t.Fatal("Absence was proven with a child moved to the other side")
+	}
+
+	// both children of the lowest two child node as a single sibling
+	present = UTXOIndex(5, 9, 0)
+	concatenated := append(AuditNodes{}, csmt.Prove(present)...)
+	level := 1
+	for ; level <= treeHeight; level++ {
+		n := concatenated[treeHeight-level]
+		if n.LeftSibling != nil && n.RightSibling != nil {
+			break
+		}
+	}
+	for l := 0; l < level; l++ {
+		n := &concatenated[treeHeight-l]
+		n.Value, n.LeftSibling, n.RightSibling = nil, nil, nil
+	}
+	n := &concatenated[treeHeight-level]
+	pair := append(append([]byte{}, n.LeftSibling...), n.RightSibling...)
+	if present>>(level-1)&1 == 0 {
+		n.LeftSibling, n.RightSibling = nil, pair
+	} else {
+		n.LeftSibling, n.RightSibling = pair, nil
+	}
+	if err := concatenated.VerifyAbsence(treeHeight, present, root); err == nil {
+		t.Fatal("Absence was proven with a pair of children as a sibling")
+	}
+}
+
+func TestProveNodeOutOfTree(t *testing.T) {
+	csmt := subtreeTestTree()
+	if csmt.ProveNode(treeHeight+1, 0) != nil {
+		t.Fatal("Path was built for a node above the root")
+	}
+	if csmt.ProveNode(blockLevel, 1<<(treeHeight-blockLevel)) != nil {
+		t.Fatal("Path was built for a node index out of the tree")
+	}
+	if csmt.Prove(1<<treeHeight) != nil {
+		t.Fatal("Path was built for a leaf out of the tree")
+	}
+	if len(csmt.ProveNode(treeHeight, 0)) != 1 {
+		t.Fatal("Root path has unexpected length")
+	}
+}
@@ -0,0 +1,94 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"errors"
+)
+
+// TransitionRoots recomputes the roots before and after a batch of changes from the audit
+// set of that batch. oldLeaves and newLeaves map every touched index to the leaf hash before
+// and after the batch, nil for an empty leaf. Nodes off the touched paths are taken from the
+// siblings recorded in the audit set, and as the same siblings are used for both roots,
+// matching the old root against a trusted one authenticates them.
+// The audit set itself is checked to contain exactly the touched nodes with their new values.
+func (d AuditNodes) TransitionRoots(h Hasher, height uint8, oldLeaves, newLeaves map[uint64][]byte) ([]byte, []byte, error) {
+	if len(oldLeaves) != len(newLeaves) {
+		return nil, nil, errors.New("Old and new leaves do not match")
+	}
+	if len(newLeaves) == 0 {
+		return nil, nil, errors.New("No touched leaves")
+	}
+	nodes := make(map[string]AuditNode, len(d))
+	for _, n := range d {
+		key := cacheKey(n.Level, n.Index)
+		if _, exists := nodes[key]; exists {
+			return nil, nil, errors.New("Duplicate node in audit set")
+		}
+		nodes[key] = n
+	}
+	used := 0
+	oldLevel := make(map[uint64][]byte, len(oldLeaves))
+	newLevel := make(map[uint64][]byte, len(newLeaves))
+	for index, hash := range newLeaves {
+		old, exists := oldLeaves[index]
+		if !exists {
+			return nil, nil, errors.New("Old and new leaves do not match")
+		}
+		if height < 64 && index >= 1<<height {
+			return nil, nil, errors.New("Leaf index is out of range")
+		}
+		n, exists := nodes[cacheKey(0, index)]
+		if !exists || bytes.Compare(n.Value, hash) != 0 {
+			return nil, nil, errors.New("Audit set does not match the new leaves")
+		}
+		used++
+		oldLevel[index], newLevel[index] = old, hash
+	}
+	for level := uint8(1); level <= height; level++ {
+		oldParents := make(map[uint64][]byte)
+		newParents := make(map[uint64][]byte)
+		for id := range newLevel {
+			parent := id >> 1
+			if _, done := newParents[parent]; done {
+				continue
+			}
+			n, exists := nodes[cacheKey(level, parent)]
+			if !exists {
+				return nil, nil, errors.New("Audit set misses a touched node")
+			}
+			used++
+			oldLeft, newLeft, err := transitionChild(oldLevel, newLevel, parent<<1, n.LeftSibling)
+			if err != nil {
+				return nil, nil, err
+			}
+			oldRight, newRight, err := transitionChild(oldLevel, newLevel, parent<<1+1, n.RightSibling)
+			if err != nil {
+				return nil, nil, err
+			}
+			newHash := h.NodeHash(newLeft, newRight)
+			if bytes.Compare(newHash, n.Value) != 0 {
+				return nil, nil, errors.New("Audit node value is invalid")
+			}
+			oldParents[parent] = h.NodeHash(oldLeft, oldRight)
+			newParents[parent] = newHash
+		}
+		oldLevel, newLevel = oldParents, newParents
+	}
+	if used != len(nodes) {
+		return nil, nil, errors.New("Audit set has nodes off the touched paths")
+	}
+	return oldLevel[0], newLevel[0], nil
+}
+
+// transitionChild returns the old and new values of a child: recomputed ones if the child
+// is touched, the recorded sibling otherwise.
+func transitionChild(oldLevel, newLevel map[uint64][]byte, id uint64, recorded []byte) ([]byte, []byte, error) {
+	newHash, touched := newLevel[id]
+	if !touched {
+		return recorded, recorded, nil
+	}
+	if bytes.Compare(newHash, recorded) != 0 {
+		return nil, nil, errors.New("Audit node children are invalid")
+	}
+	return oldLevel[id], newHash, nil
+}
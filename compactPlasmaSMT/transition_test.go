@@ -0,0 +1,49 @@
+package compactplasmasmt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTransitionRoots(t *testing.T) {
+	csmt := NewCSMT(16, true)
+	var toInsert InsertionIndexes
+	for i, idx := range []uint64{1, 7, 300, 301, 9000} {
+		toInsert = append(toInsert, InsertedIndex{Index: idx, Value: []byte{byte(i + 1)}})
+	}
+	_ = csmt.ApplyInserts(toInsert)
+	oldRoot := csmt.RootHash()
+
+	deleted := csmt.ApplyDeletes(DeletionIndexes{7, 301})
+	inserted := csmt.ApplyInserts(InsertionIndexes{{Index: 40000, Value: []byte{0xaa}}})
+	audit := MergeAuditNodes(deleted, inserted)
+
+	oldLeaves := map[uint64][]byte{7: LeafHash([]byte{2}), 301: LeafHash([]byte{4}), 40000: nil}
+	newLeaves := map[uint64][]byte{7: nil, 301: nil, 40000: LeafHash([]byte{0xaa})}
+	before, after, err := audit.TransitionRoots(SHA512_256, 16, oldLeaves, newLeaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(before, oldRoot) != 0 || bytes.Compare(after, csmt.RootHash()) != 0 {
+		t.Fatal("Recomputed roots do not match the tree")
+	}
+
+	// a forged sibling changes the old root
+	forged := append(AuditNodes{}, audit...)
+	for i := range forged {
+		if forged[i].Level == 16 {
+			forged[i].LeftSibling = LeafHash([]byte{0xff})
+		}
+	}
+	if before, _, err := forged.TransitionRoots(SHA512_256, 16, oldLeaves, newLeaves); err == nil && bytes.Compare(before, oldRoot) == 0 {
+		t.Fatal("Forged sibling was not detected")
+	}
+	if _, _, err := audit[1:].TransitionRoots(SHA512_256, 16, oldLeaves, newLeaves); err == nil {
+		t.Fatal("Audit set without the root was accepted")
+	}
+	extra := append(AuditNodes{}, audit...)
+	extra = append(extra, AuditNode{0, 5, LeafHash([]byte{5}), nil, nil})
+	if _, _, err := extra.TransitionRoots(SHA512_256, 16, oldLeaves, newLeaves); err == nil {
+		t.Fatal("Audit set with an extra node was accepted")
+	}
+}
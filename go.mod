@@ -0,0 +1,10 @@
+module github.com/matterinc/PlasmaCompact
+
+go 1.21
+
+require (
+	github.com/btcsuite/btcd v0.22.1
+	golang.org/x/crypto v0.9.0
+)
+
+require golang.org/x/sys v0.8.0 // indirect
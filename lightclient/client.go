@@ -0,0 +1,160 @@
+// Package lightclient follows the chain by headers only. Every header is checked to link to
+// the previous one and to be signed by the operator, and the SMT roots of the last blocks are
+// kept to check proofs of outputs without the tree.
+package lightclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+	"github.com/matterinc/PlasmaCompact/plasma"
+)
+
+// HeaderSource publishes block headers, e.g. the root chain contract or the operator API.
+type HeaderSource interface {
+	// Height returns the number of the latest published block, 0 if there are none.
+	Height() (uint64, error)
+	// Header returns the header of a published block.
+	Header(number uint64) (*plasma.BlockHeader, error)
+}
+
+// MemoryHeaderSource keeps published headers in memory, it checks nothing.
+type MemoryHeaderSource struct {
+	mu      sync.RWMutex
+	headers []*plasma.BlockHeader
+}
+
+func NewMemoryHeaderSource() *MemoryHeaderSource {
+	return new(MemoryHeaderSource)
+}
+
+// Publish appends the header as the next block.
+func (s *MemoryHeaderSource) Publish(h *plasma.BlockHeader) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.headers = append(s.headers, h)
+}
+
+func (s *MemoryHeaderSource) Height() (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return uint64(len(s.headers)), nil
+}
+
+func (s *MemoryHeaderSource) Header(number uint64) (*plasma.BlockHeader, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if number == 0 || number > uint64(len(s.headers)) {
+		return nil, fmt.Errorf("Block %v is not published", number)
+	}
+	return s.headers[number-1], nil
+}
+
+// Client is a light client of a single operator.
+type Client struct {
+	mu       sync.RWMutex
+	operator []byte
+	source   HeaderSource
+	window   int
+	last     *plasma.BlockHeader
+	roots    [][]byte // roots of the last blocks, the last one is of the last header
+}
+
+// New creates a client that keeps the roots of the last window blocks. It starts after the
+// trusted header, nil for a new chain.
+func New(operator []byte, source HeaderSource, window int, trusted *plasma.BlockHeader) *Client {
+	if window < 1 {
+		window = 1
+	}
+	c := &Client{operator: operator, source: source, window: window, last: trusted}
+	if trusted != nil {
+		c.roots = [][]byte{trusted.SMTRoot}
+	}
+	return c
+}
+
+// Last returns the last accepted header, nil if there is none.
+func (c *Client) Last() *plasma.BlockHeader {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.last
+}
+
+// AddHeader accepts the next header if it follows the last one and is signed by the operator.
+func (c *Client) AddHeader(h *plasma.BlockHeader) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var prevHash []byte
+	number := uint64(1)
+	if c.last != nil {
+		var err error
+		if prevHash, err = c.last.Hash(); err != nil {
+			return err
+		}
+		number = c.last.Number + 1
+	}
+	if h.Number != number || h.Number > plasma.MaxBlockNumber {
+		return fmt.Errorf("Expected block %v, got %v", number, h.Number)
+	}
+	if bytes.Compare(h.PrevHash, prevHash) != 0 {
+		return errors.New("Header does not link to the last one")
+	}
+	if err := h.VerifySignature(c.operator); err != nil {
+		return err
+	}
+	c.last = h
+	c.roots = append(c.roots, h.SMTRoot)
+	if len(c.roots) > c.window {
+		c.roots = c.roots[len(c.roots)-c.window:]
+	}
+	return nil
+}
+
+// Sync fetches and checks all the headers published after the last one and returns how many
+// were accepted. It stops at the first invalid header.
+func (c *Client) Sync() (int, error) {
+	height, err := c.source.Height()
+	if err != nil {
+		return 0, err
+	}
+	accepted := 0
+	for {
+		next := uint64(1)
+		if last := c.Last(); last != nil {
+			next = last.Number + 1
+		}
+		if next > height {
+			return accepted, nil
+		}
+		h, err := c.source.Header(next)
+		if err != nil {
+			return accepted, err
+		}
+		if err := c.AddHeader(h); err != nil {
+			return accepted, fmt.Errorf("Block %v: %v", next, err)
+		}
+		accepted++
+	}
+}
+
+// Root returns the SMT root after the block if it is still in the window.
+func (c *Client) Root(number uint64) ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.last == nil || number > c.last.Number || c.last.Number-number >= uint64(len(c.roots)) {
+		return nil, fmt.Errorf("Root of block %v is not tracked", number)
+	}
+	return c.roots[uint64(len(c.roots))-1-(c.last.Number-number)], nil
+}
+
+// VerifyProof checks that the leaf had the value after the block.
+func (c *Client) VerifyProof(number, index uint64, value []byte, proof csmt.AuditNodes) error {
+	root, err := c.Root(number)
+	if err != nil {
+		return err
+	}
+	return proof.VefiryPath(plasma.TreeHeight, index, value, root)
+}
@@ -0,0 +1,111 @@
+package lightclient
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+	"github.com/matterinc/PlasmaCompact/plasma"
+)
+
+func testSigner(t *testing.T, seed byte) plasma.Signer {
+	signer, err := plasma.NewEd25519Signer(bytes.Repeat([]byte{seed}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// testChain publishes blocks with a single deposit each.
+func testChain(t *testing.T, blocks int) (*plasma.BlockProducer, *MemoryHeaderSource) {
+	producer := plasma.NewBlockProducer(csmt.NewCSMT(plasma.TreeHeight, true), testSigner(t, 0xff))
+	source := NewMemoryHeaderSource()
+	feed := plasma.NewMemoryDepositFeed()
+	ledger := plasma.NewDepositLedger(0)
+	var last *plasma.BlockHeader
+	for i := 0; i < blocks; i++ {
+		feed.Deposit(testSigner(t, 0x01).PubKey(), plasma.NativeToken, big.NewInt(int64(10+i)))
+		deposits, _ := feed.Deposits(ledger.Next(), -1)
+		produced, err := producer.ProduceDeposits(last, ledger, deposits, uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ledger.Commit(deposits)
+		last = &produced.Block.Header
+		source.Publish(last)
+	}
+	return producer, source
+}
+
+func TestLightClientTracksRoots(t *testing.T) {
+	producer, source := testChain(t, 5)
+	c := New(testSigner(t, 0xff).PubKey(), source, 3, nil)
+	accepted, err := c.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted != 5 || c.Last().Number != 5 {
+		t.Fatal("Headers were not synced")
+	}
+	index := csmt.UTXOIndex(3, 0, 0)
+	value := producer.Tree().Leaf(index)
+	if err := c.VerifyProof(5, index, value, producer.Tree().Prove(index)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.VerifyProof(4, index, value, producer.Tree().Prove(index)); err == nil {
+		t.Fatal("Proof was valid against an older root")
+	}
+	if _, err := c.Root(2); err == nil {
+		t.Fatal("Root out of the window was returned")
+	}
+	if _, err := c.Root(6); err == nil {
+		t.Fatal("Root of an unknown block was returned")
+	}
+	if accepted, err := c.Sync(); err != nil || accepted != 0 {
+		t.Fatal("Synced client accepted headers")
+	}
+
+	// a client can start from a trusted header
+	trusted, _ := source.Header(4)
+	c = New(testSigner(t, 0xff).PubKey(), source, 3, trusted)
+	if accepted, err := c.Sync(); err != nil || accepted != 1 {
+		t.Fatal("Client did not sync from a trusted header")
+	}
+}
+
+func TestLightClientRejectsInvalidHeaders(t *testing.T) {
+	_, source := testChain(t, 2)
+	operator := testSigner(t, 0xff).PubKey()
+	first, _ := source.Header(1)
+	second, _ := source.Header(2)
+
+	c := New(testSigner(t, 0x01).PubKey(), source, 3, nil)
+	if _, err := c.Sync(); err == nil {
+		t.Fatal("Header signed by somebody else was accepted")
+	}
+
+	c = New(operator, source, 3, nil)
+	if err := c.AddHeader(second); err == nil {
+		t.Fatal("Header that skips a block was accepted")
+	}
+	if err := c.AddHeader(first); err != nil {
+		t.Fatal(err)
+	}
+	forged := *second
+	forged.PrevHash = bytes.Repeat([]byte{0x01}, 32)
+	if err := forged.Sign(testSigner(t, 0xff)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddHeader(&forged); err == nil {
+		t.Fatal("Header with an invalid parent hash was accepted")
+	}
+	forged = *second
+	forged.SMTRoot = bytes.Repeat([]byte{0x01}, 32)
+	if err := c.AddHeader(&forged); err == nil {
+		t.Fatal("Header with a changed root was accepted")
+	}
+	if err := c.AddHeader(second); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,111 @@
+// Package onchain prepares tree proofs for Ethereum contracts. A single leaf proof is
+// encoded as a bitmap of non-empty siblings and the packed list of those siblings, and the
+// package models the contract side verification, including its gas cost, so the encoding
+// and the contract can be checked against the Go tree. Trees have to use the Keccak256 hasher.
+package onchain
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+// WordLength is the length of an ABI word and of a Keccak256 hash.
+const WordLength = 32
+
+// Proof is a single leaf proof as the contract takes it. Bit i of the bitmap is set if the
+// sibling on the path at level i, counting from the leaves, is not empty, and the non-empty
+// siblings go in the same bottom up order.
+type Proof struct {
+	Height   uint8
+	Index    uint64
+	Bitmap   uint64
+	Siblings [][WordLength]byte
+}
+
+// FromAuditNodes converts a path as Prove and FilterPath return it.
+func FromAuditNodes(p csmt.AuditNodes, height uint8) (*Proof, error) {
+	if height == 0 || height > 64 {
+		return nil, errors.New("Tree height is out of range")
+	}
+	if len(p) != int(height)+1 {
+		return nil, errors.New("Path length is invalid")
+	}
+	proof := &Proof{Height: height, Index: p[height].Index}
+	if height < 64 && proof.Index>>height != 0 {
+		return nil, errors.New("Index is out of the tree")
+	}
+	for level := uint8(0); level < height; level++ {
+		n := p[height-1-level]
+		if n.Level != level+1 || n.Index != proof.Index>>(level+1) {
+			return nil, errors.New("Path does not lead to the leaf")
+		}
+		sibling := n.RightSibling
+		if proof.Index>>level&1 == 1 {
+			sibling = n.LeftSibling
+		}
+		if sibling == nil {
+			continue
+		}
+		if len(sibling) != WordLength {
+			return nil, errors.New("Sibling is not a Keccak256 hash")
+		}
+		var word [WordLength]byte
+		copy(word[:], sibling)
+		proof.Bitmap |= 1 << level
+		proof.Siblings = append(proof.Siblings, word)
+	}
+	return proof, nil
+}
+
+// EncodeABI returns the proof as abi.encode(uint256 bitmap, bytes32[] siblings).
+func (p *Proof) EncodeABI() []byte {
+	encoded := make([]byte, 3*WordLength, (3+len(p.Siblings))*WordLength)
+	binary.BigEndian.PutUint64(encoded[WordLength-8:], p.Bitmap)
+	encoded[2*WordLength-1] = 2 * WordLength // offset of the array
+	binary.BigEndian.PutUint64(encoded[3*WordLength-8:], uint64(len(p.Siblings)))
+	for i := range p.Siblings {
+		encoded = append(encoded, p.Siblings[i][:]...)
+	}
+	return encoded
+}
+
+// DecodeABI decodes EncodeABI output for a leaf of a tree of the given height. Only the
+// canonical encoding is accepted: the bitmap has no bits above the height and has as many
+// bits set as there are siblings.
+func DecodeABI(data []byte, height uint8, index uint64) (*Proof, error) {
+	if height == 0 || height > 64 {
+		return nil, errors.New("Tree height is out of range")
+	}
+	if len(data) < 3*WordLength || len(data)%WordLength != 0 {
+		return nil, errors.New("Invalid ABI encoded proof length")
+	}
+	if !isZero(data[:WordLength-8]) || !isZero(data[WordLength:2*WordLength-1]) || data[2*WordLength-1] != 2*WordLength ||
+		!isZero(data[2*WordLength:3*WordLength-8]) {
+		return nil, errors.New("Invalid ABI encoded proof head")
+	}
+	p := &Proof{Height: height, Index: index, Bitmap: binary.BigEndian.Uint64(data[WordLength-8:])}
+	if height < 64 && (p.Bitmap>>height != 0 || index>>height != 0) {
+		return nil, errors.New("Proof is out of the tree")
+	}
+	count := binary.BigEndian.Uint64(data[3*WordLength-8:])
+	if count != uint64(len(data)/WordLength-3) || count != uint64(bits.OnesCount64(p.Bitmap)) {
+		return nil, errors.New("Number of siblings does not match the bitmap")
+	}
+	p.Siblings = make([][WordLength]byte, count)
+	for i := range p.Siblings {
+		copy(p.Siblings[i][:], data[(3+i)*WordLength:])
+	}
+	return p, nil
+}
+
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
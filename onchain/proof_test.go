@@ -0,0 +1,94 @@
+package onchain
+
+import (
+	"bytes"
+	"testing"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+func testTree(hasher csmt.Hasher) *csmt.CSMT {
+	tree := csmt.NewCSMTWithHasher(48, true, hasher)
+	tree.ApplyInserts(csmt.InsertionIndexes{
+		{Index: csmt.UTXOIndex(1, 0, 0), Value: []byte{0x01}},
+		{Index: csmt.UTXOIndex(1, 0, 1), Value: []byte{0x02}},
+		{Index: csmt.UTXOIndex(2, 7, 0), Value: []byte{0x03}},
+	})
+	return tree
+}
+
+func TestProofEncoding(t *testing.T) {
+	tree := testTree(csmt.Keccak256)
+	index := csmt.UTXOIndex(1, 0, 1)
+	p, err := FromAuditNodes(tree.Prove(index), 48)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// siblings: the other output of the transaction and the subtree of block 2, the
+	// block numbers first differ at bit 1, that is at level 24+1
+	if len(p.Siblings) != 2 || p.Bitmap != 1|1<<25 {
+		t.Fatalf("Unexpected bitmap %b", p.Bitmap)
+	}
+	encoded := p.EncodeABI()
+	if len(encoded) != 5*WordLength {
+		t.Fatal("Invalid ABI encoding length")
+	}
+	decoded, err := DecodeABI(encoded, 48, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(decoded.EncodeABI(), encoded) != 0 {
+		t.Fatal("Proof changed after a round trip")
+	}
+	var root [WordLength]byte
+	copy(root[:], tree.RootHash())
+	if valid, err := decoded.Verify(root, []byte{0x02}); err != nil || !valid {
+		t.Fatal("Valid proof was rejected")
+	}
+	if valid, _ := decoded.Verify(root, []byte{0x01}); valid {
+		t.Fatal("Proof was valid for another value")
+	}
+	// level 1 has no sibling, single children are hashed with their side
+	flipped := &Proof{Height: 48, Index: index ^ 1<<1, Bitmap: p.Bitmap, Siblings: p.Siblings}
+	if valid, err := flipped.Verify(root, []byte{0x02}); err != nil || valid {
+		t.Fatal("Proof was valid for another index")
+	}
+
+	for _, malformed := range [][]byte{
+		encoded[:len(encoded)-1],
+		encoded[:len(encoded)-WordLength],
+		append(append([]byte{}, encoded...), make([]byte, WordLength)...),
+	} {
+		if _, err := DecodeABI(malformed, 48, index); err == nil {
+			t.Fatal("Malformed proof was decoded")
+		}
+	}
+	extraBit := append([]byte{}, encoded...)
+	extraBit[WordLength-1] |= 0x02
+	if _, err := DecodeABI(extraBit, 48, index); err == nil {
+		t.Fatal("Bitmap that does not match the siblings was decoded")
+	}
+	if _, err := DecodeABI(encoded, 48, 1<<48); err == nil {
+		t.Fatal("Index out of the tree was decoded")
+	}
+	if _, err := (&Proof{Height: 48, Index: index, Bitmap: p.Bitmap, Siblings: p.Siblings[:1]}).Verify(root, []byte{0x02}); err == nil {
+		t.Fatal("Proof with missing siblings did not revert")
+	}
+}
+
+func TestProofNeedsKeccakTree(t *testing.T) {
+	tree := testTree(csmt.SHA512_256)
+	index := csmt.UTXOIndex(2, 7, 0)
+	p, err := FromAuditNodes(tree.Prove(index), 48)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root [WordLength]byte
+	copy(root[:], tree.RootHash())
+	if valid, _ := p.Verify(root, []byte{0x03}); valid {
+		t.Fatal("Proof of a SHA512/256 tree was accepted")
+	}
+	if _, err := FromAuditNodes(tree.Prove(index)[1:], 48); err == nil {
+		t.Fatal("Short path was converted")
+	}
+}
@@ -0,0 +1,150 @@
+package onchain
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+// TestVector is a verify call for contract tests. Byte fields are 0x prefixed hex.
+type TestVector struct {
+	Name     string   `json:"name"`
+	Height   uint8    `json:"height"`
+	Root     string   `json:"root"`
+	Index    uint64   `json:"index"`
+	Value    string   `json:"value"`
+	Bitmap   string   `json:"bitmap"` // uint256 word
+	Siblings []string `json:"siblings"`
+	Proof    string   `json:"proof"` // EncodeABI
+	Calldata string   `json:"calldata"`
+	Valid    bool     `json:"valid"`
+	Gas      Gas      `json:"gas"`
+}
+
+// newVector checks the proof with both the model and VerifyPathWith, they must agree.
+func newVector(name string, path csmt.AuditNodes, height uint8, value []byte, root [WordLength]byte) (*TestVector, error) {
+	p, err := FromAuditNodes(path, height)
+	if err != nil {
+		return nil, err
+	}
+	valid, err := p.Verify(root, value)
+	if err != nil {
+		return nil, err
+	}
+	expected := path.VerifyPathWith(csmt.Keccak256, height, p.Index, value, root[:]) == nil
+	if valid != expected {
+		return nil, fmt.Errorf("%v: model returned %v, the tree %v", name, valid, expected)
+	}
+	encoded := p.EncodeABI()
+	v := &TestVector{
+		Name:     name,
+		Height:   height,
+		Root:     csmt.EncodeHex(root[:]),
+		Index:    p.Index,
+		Value:    csmt.EncodeHex(value),
+		Bitmap:   csmt.EncodeHex(encoded[:WordLength]),
+		Siblings: make([]string, len(p.Siblings)),
+		Proof:    csmt.EncodeHex(encoded),
+		Calldata: csmt.EncodeHex(p.Calldata(root, value)),
+		Valid:    valid,
+		Gas:      p.EstimateGas(root, value),
+	}
+	for i := range p.Siblings {
+		v.Siblings[i] = csmt.EncodeHex(p.Siblings[i][:])
+	}
+	return v, nil
+}
+
+// GenerateVectors builds a Keccak256 tree with random leaves and returns valid proofs of
+// count of them, each one followed by invalid ones: a wrong value, a wrong sibling and, if
+// the path has an empty sibling, the same siblings for the index with a bit flipped at the
+// level of that sibling.
+// Every vector is checked against VerifyPathWith.
+func GenerateVectors(seed int64, height uint8, leaves, count int) ([]TestVector, error) {
+	if height == 0 || height > 63 {
+		return nil, errors.New("Tree height is out of range")
+	}
+	if leaves < 2 || count > leaves || uint64(leaves) > uint64(1)<<height/2 {
+		return nil, errors.New("Invalid number of leaves")
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	tree := csmt.NewCSMTWithHasher(height, true, csmt.Keccak256)
+	used := make(map[uint64]bool)
+	var toInsert csmt.InsertionIndexes
+	for len(toInsert) < leaves {
+		index := uint64(rnd.Int63n(int64(uint64(1) << height)))
+		if used[index] {
+			continue
+		}
+		used[index] = true
+		value := make([]byte, 1+rnd.Intn(3*WordLength))
+		rnd.Read(value)
+		toInsert = append(toInsert, csmt.InsertedIndex{Index: index, Value: value})
+	}
+	sort.Sort(toInsert)
+	tree.ApplyInserts(toInsert)
+	var root [WordLength]byte
+	copy(root[:], tree.RootHash())
+
+	var vectors []TestVector
+	for _, i := range rnd.Perm(leaves)[:count] {
+		leaf := toInsert[i]
+		path := tree.Prove(leaf.Index)
+		v, err := newVector(fmt.Sprintf("leaf %v", leaf.Index), path, height, leaf.Value, root)
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, *v)
+
+		wrong := append([]byte{}, leaf.Value...)
+		wrong[0] ^= 0x01
+		if v, err = newVector(fmt.Sprintf("leaf %v with a wrong value", leaf.Index), path, height, wrong, root); err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, *v)
+
+		// the lowest non-empty sibling is replaced, on the same side of the path
+		tampered := append(csmt.AuditNodes{}, path...)
+		for j := len(tampered) - 2; j >= 0; j-- {
+			n := &tampered[j]
+			if n.LeftSibling != nil && n.RightSibling != nil {
+				if leaf.Index>>(tampered[j].Level-1)&1 == 0 {
+					n.RightSibling = keccak256(n.RightSibling)
+				} else {
+					n.LeftSibling = keccak256(n.LeftSibling)
+				}
+				break
+			}
+		}
+		if v, err = newVector(fmt.Sprintf("leaf %v with a wrong sibling", leaf.Index), tampered, height, leaf.Value, root); err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, *v)
+
+		// the lowest empty sibling is moved to the other side, which keeps the bitmap
+		flipped := append(csmt.AuditNodes{}, path...)
+		found := false
+		for j := len(flipped) - 2; j >= 0 && !found; j-- {
+			n := &flipped[j]
+			if found = n.LeftSibling == nil || n.RightSibling == nil; found {
+				n.LeftSibling, n.RightSibling = n.RightSibling, n.LeftSibling
+				bit := uint64(1) << (n.Level - 1)
+				for k := j + 1; k < len(flipped); k++ {
+					flipped[k].Index = (leaf.Index ^ bit) >> flipped[k].Level
+				}
+			}
+		}
+		if !found {
+			continue
+		}
+		name := fmt.Sprintf("leaf %v at index %v", leaf.Index, flipped[len(flipped)-1].Index)
+		if v, err = newVector(name, flipped, height, leaf.Value, root); err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, *v)
+	}
+	return vectors, nil
+}
@@ -0,0 +1,38 @@
+package onchain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGenerateVectors(t *testing.T) {
+	vectors, err := GenerateVectors(1, 48, 64, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 4*8 {
+		t.Fatal("Unexpected number of vectors")
+	}
+	for i, v := range vectors {
+		if v.Valid != (i%4 == 0) {
+			t.Fatalf("Vector %v has an unexpected result", v.Name)
+		}
+		if v.Gas.Execution == 0 || v.Gas.Calldata == 0 || v.Gas.Total() <= GasTransaction {
+			t.Fatal("Gas was not estimated")
+		}
+		// 4 byte selector, 6 head and length words, the value and the siblings
+		if len(v.Calldata) < 2+2*(4+6*WordLength+len(v.Siblings)*WordLength) {
+			t.Fatal("Calldata is too short")
+		}
+	}
+	again, _ := GenerateVectors(1, 48, 64, 8)
+	first, _ := json.Marshal(vectors)
+	second, _ := json.Marshal(again)
+	if string(first) != string(second) {
+		t.Fatal("Vectors are not deterministic")
+	}
+	t.Logf("%v: %v execution and %v calldata gas", vectors[0].Name, vectors[0].Gas.Execution, vectors[0].Gas.Calldata)
+	if _, err := GenerateVectors(1, 8, 200, 8); err == nil {
+		t.Fatal("More leaves than the tree takes were accepted")
+	}
+}
@@ -0,0 +1,156 @@
+package onchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+	"golang.org/x/crypto/sha3"
+)
+
+// VerifyFunction is the signature of the contract function the proofs are made for:
+//
+//	function verify(bytes32 root, uint256 index, bytes value, uint256 bitmap, bytes32[] siblings)
+//	    public pure returns (bool)
+//	{
+//	    require(index >> HEIGHT == 0 && bitmap >> HEIGHT == 0);
+//	    bytes32 node = keccak256(abi.encodePacked(LEAF, value));
+//	    uint256 next = 0;
+//	    for (uint256 level = 0; level < HEIGHT; level++) {
+//	        if (bitmap & (1 << level) == 0) {
+//	            node = keccak256(abi.encodePacked(index & 1 == 0 ? LEFT : RIGHT, node));
+//	        } else if (index & 1 == 0) {
+//	            node = keccak256(abi.encodePacked(PAIR, node, siblings[next++]));
+//	        } else {
+//	            node = keccak256(abi.encodePacked(PAIR, siblings[next++], node));
+//	        }
+//	        index >>= 1;
+//	    }
+//	    require(next == siblings.length);
+//	    return node == root;
+//	}
+//
+// LEAF, LEFT, RIGHT and PAIR are the bytes1 tags of the tree hashes. The node on the path is
+// never empty as it is above a leaf, so a node with a single child is the tagged hash of
+// that child alone, like in the tree.
+const VerifyFunction = "verify(bytes32,uint256,bytes,uint256,bytes32[])"
+
+// Gas costs of the EVM operations the verification is made of. The per level and fixed
+// overheads are estimates for the compiled loop above and the ABI decoding, the rest are
+// the protocol costs.
+const (
+	GasKeccakBase      = 30
+	GasKeccakWord      = 6
+	GasCalldataZero    = 4
+	GasCalldataNonZero = 16
+	GasTransaction     = 21000
+	GasPerLevel        = 60
+	GasPerSibling      = 12
+	GasOverhead        = 700
+)
+
+// Gas is an estimate of the cost of a verify call.
+type Gas struct {
+	Execution uint64 `json:"execution"`
+	Calldata  uint64 `json:"calldata"`
+}
+
+// Total includes the base cost of a transaction.
+func (g Gas) Total() uint64 {
+	return GasTransaction + g.Execution + g.Calldata
+}
+
+func keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func keccakGas(length int) uint64 {
+	return GasKeccakBase + GasKeccakWord*uint64((length+WordLength-1)/WordLength)
+}
+
+// Verify runs the contract algorithm step by step. An error is a revert of the call. Every
+// bit of the index is used, to order a pair or to tag a single child, so a proof is valid
+// for one position only.
+func (p *Proof) Verify(root [WordLength]byte, value []byte) (bool, error) {
+	if p.Height == 0 || p.Height > 64 {
+		return false, errors.New("Tree height is out of range")
+	}
+	if p.Height < 64 && (p.Index>>p.Height != 0 || p.Bitmap>>p.Height != 0) {
+		return false, errors.New("Proof is out of the tree")
+	}
+	node := keccak256([]byte{csmt.LeafTag}, value)
+	index := p.Index
+	next := 0
+	for level := uint8(0); level < p.Height; level++ {
+		if p.Bitmap&(1<<level) == 0 {
+			if index&1 == 0 {
+				node = keccak256([]byte{csmt.LeftTag}, node)
+			} else {
+				node = keccak256([]byte{csmt.RightTag}, node)
+			}
+		} else {
+			if next == len(p.Siblings) {
+				return false, errors.New("Not enough siblings")
+			}
+			if index&1 == 0 {
+				node = keccak256([]byte{csmt.PairTag}, node, p.Siblings[next][:])
+			} else {
+				node = keccak256([]byte{csmt.PairTag}, p.Siblings[next][:], node)
+			}
+			next++
+		}
+		index >>= 1
+	}
+	if next != len(p.Siblings) {
+		return false, errors.New("Unused siblings")
+	}
+	return bytes.Compare(node, root[:]) == 0, nil
+}
+
+// Calldata returns the input of the verify call.
+func (p *Proof) Calldata(root [WordLength]byte, value []byte) []byte {
+	word := func(v uint64) []byte {
+		w := make([]byte, WordLength)
+		binary.BigEndian.PutUint64(w[WordLength-8:], v)
+		return w
+	}
+	paddedValue := (len(value) + WordLength - 1) / WordLength * WordLength
+	data := append([]byte{}, keccak256([]byte(VerifyFunction))[:4]...)
+	data = append(data, root[:]...)
+	data = append(data, word(p.Index)...)
+	data = append(data, word(5*WordLength)...)
+	data = append(data, word(p.Bitmap)...)
+	data = append(data, word(uint64(6*WordLength+paddedValue))...)
+	data = append(data, word(uint64(len(value)))...)
+	data = append(data, value...)
+	data = append(data, make([]byte, paddedValue-len(value))...)
+	data = append(data, word(uint64(len(p.Siblings)))...)
+	for i := range p.Siblings {
+		data = append(data, p.Siblings[i][:]...)
+	}
+	return data
+}
+
+// EstimateGas estimates the cost of the verify call Calldata describes.
+func (p *Proof) EstimateGas(root [WordLength]byte, value []byte) Gas {
+	var g Gas
+	for _, b := range p.Calldata(root, value) {
+		if b == 0 {
+			g.Calldata += GasCalldataZero
+		} else {
+			g.Calldata += GasCalldataNonZero
+		}
+	}
+	g.Execution = GasOverhead + keccakGas(1+len(value))
+	siblings := uint64(len(p.Siblings))
+	single := uint64(p.Height) - siblings
+	g.Execution += uint64(p.Height) * GasPerLevel
+	g.Execution += siblings * (GasPerSibling + keccakGas(1+2*WordLength))
+	g.Execution += single * keccakGas(1+WordLength)
+	return g
+}
@@ -0,0 +1,293 @@
+package plasma
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+// MaxTransactions is the number of transactions that fit into the 20 bit transaction prefix.
+const MaxTransactions = 1 << 20
+
+// BlockHeader commits to the block transactions, to the tree of unspent outputs after the
+// block and to the audit data clients use to update their proofs.
+type BlockHeader struct {
+	Number          uint64
+	PrevHash        []byte // hash of the previous header, empty for the first block
+	TxRoot          []byte
+	SMTRoot         []byte
+	AuditCommitment []byte
+	Timestamp       uint64 // unix time in seconds
+	// Deposit blocks mint one parent chain deposit per transaction, starting from DepositNonce,
+	// and set Deposits to their number. Both are zero for transaction blocks.
+	Deposits     uint64
+	DepositNonce uint64
+	Signature    []byte // operator's signature over the header hash
+}
+
+// Block is a header and the transactions it commits to.
+type Block struct {
+	Header       BlockHeader
+	Transactions []*Transaction
+}
+
+// Encoding layout, all integers are big endian:
+//
+//	header: number (8 bytes) | prev hash | tx root | SMT root | audit commitment | timestamp (8 bytes) | deposits (8 bytes) | deposit nonce (8 bytes) | signature
+//	every hash and the signature are prefixed by their length (1 byte)
+//	block: header length (4 bytes) | header | number of transactions (4 bytes) | length (4 bytes) and transaction, for every transaction
+
+func appendBytes(encoded []byte, field []byte) ([]byte, error) {
+	if len(field) > 255 {
+		return nil, errors.New("Header field is too long")
+	}
+	encoded = append(encoded, uint8(len(field)))
+	return append(encoded, field...), nil
+}
+
+// Encode returns the canonical encoding of the header.
+func (h *BlockHeader) Encode() ([]byte, error) {
+	return h.encode(true)
+}
+
+// encode optionally leaves out the signature, which is what the operator signs.
+func (h *BlockHeader) encode(withSignature bool) ([]byte, error) {
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, h.Number)
+	var err error
+	for _, field := range [][]byte{h.PrevHash, h.TxRoot, h.SMTRoot, h.AuditCommitment} {
+		encoded, err = appendBytes(encoded, field)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, field := range []uint64{h.Timestamp, h.Deposits, h.DepositNonce} {
+		value := make([]byte, 8)
+		binary.BigEndian.PutUint64(value, field)
+		encoded = append(encoded, value...)
+	}
+	if !withSignature {
+		return encoded, nil
+	}
+	return appendBytes(encoded, h.Signature)
+}
+
+func DecodeBlockHeader(data []byte) (*BlockHeader, error) {
+	if len(data) < 8 {
+		return nil, errors.New("Header is too short")
+	}
+	h := new(BlockHeader)
+	h.Number = binary.BigEndian.Uint64(data)
+	offset := 8
+	fields := make([][]byte, 4)
+	for i := range fields {
+		if len(data) <= offset {
+			return nil, errors.New("Header is too short")
+		}
+		length := int(data[offset])
+		offset++
+		if len(data) < offset+length {
+			return nil, errors.New("Header is too short")
+		}
+		if length != 0 {
+			fields[i] = append([]byte{}, data[offset:offset+length]...)
+		}
+		offset += length
+	}
+	h.PrevHash, h.TxRoot, h.SMTRoot, h.AuditCommitment = fields[0], fields[1], fields[2], fields[3]
+	if len(data) < offset+25 {
+		return nil, errors.New("Header is too short")
+	}
+	h.Timestamp = binary.BigEndian.Uint64(data[offset:])
+	h.Deposits = binary.BigEndian.Uint64(data[offset+8:])
+	h.DepositNonce = binary.BigEndian.Uint64(data[offset+16:])
+	offset += 24
+	length := int(data[offset])
+	offset++
+	if len(data) != offset+length {
+		return nil, errors.New("Invalid header length")
+	}
+	if length != 0 {
+		h.Signature = append([]byte{}, data[offset:]...)
+	}
+	return h, nil
+}
+
+// Hash returns the hash of the canonical header encoding without the signature.
+func (h *BlockHeader) Hash() ([]byte, error) {
+	encoded, err := h.encode(false)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha512.Sum512_256(encoded)
+	return hash[:], nil
+}
+
+// Sign sets the operator's signature over the header hash.
+func (h *BlockHeader) Sign(signer Signer) error {
+	hash, err := h.Hash()
+	if err != nil {
+		return err
+	}
+	signature, err := signer.Sign(hash)
+	if err != nil {
+		return err
+	}
+	h.Signature = signature
+	return nil
+}
+
+// VerifySignature checks that the header is signed by the operator.
+func (h *BlockHeader) VerifySignature(operator []byte) error {
+	hash, err := h.Hash()
+	if err != nil {
+		return err
+	}
+	return VerifySignature(operator, hash, h.Signature)
+}
+
+// Encode returns the canonical encoding of the block.
+func (b *Block) Encode() ([]byte, error) {
+	if len(b.Transactions) > MaxTransactions {
+		return nil, errors.New("Too many transactions")
+	}
+	header, err := b.Header.Encode()
+	if err != nil {
+		return nil, err
+	}
+	encoded := make([]byte, 4)
+	binary.BigEndian.PutUint32(encoded, uint32(len(header)))
+	encoded = append(encoded, header...)
+	count := make([]byte, 4)
+	binary.BigEndian.PutUint32(count, uint32(len(b.Transactions)))
+	encoded = append(encoded, count...)
+	for _, tx := range b.Transactions {
+		encodedTx, err := tx.Encode()
+		if err != nil {
+			return nil, err
+		}
+		length := make([]byte, 4)
+		binary.BigEndian.PutUint32(length, uint32(len(encodedTx)))
+		encoded = append(encoded, length...)
+		encoded = append(encoded, encodedTx...)
+	}
+	return encoded, nil
+}
+
+func DecodeBlock(data []byte) (*Block, error) {
+	offset := 0
+	readLength := func() (int, error) {
+		if len(data) < offset+4 {
+			return 0, errors.New("Block is too short")
+		}
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		offset += 4
+		return length, nil
+	}
+	headerLength, err := readLength()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < offset+headerLength {
+		return nil, errors.New("Block is too short")
+	}
+	header, err := DecodeBlockHeader(data[offset : offset+headerLength])
+	if err != nil {
+		return nil, err
+	}
+	offset += headerLength
+	count, err := readLength()
+	if err != nil {
+		return nil, err
+	}
+	if count > MaxTransactions || count > (len(data)-offset)/4 {
+		return nil, errors.New("Invalid number of transactions")
+	}
+	b := &Block{Header: *header, Transactions: make([]*Transaction, count)}
+	for i := range b.Transactions {
+		length, err := readLength()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) < offset+length {
+			return nil, errors.New("Block is too short")
+		}
+		b.Transactions[i], err = DecodeTransaction(data[offset : offset+length])
+		if err != nil {
+			return nil, err
+		}
+		offset += length
+	}
+	if offset != len(data) {
+		return nil, errors.New("Trailing bytes after block")
+	}
+	return b, nil
+}
+
+func (b *Block) transactionHashes() ([][]byte, error) {
+	hashes := make([][]byte, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		hash, err := tx.Hash()
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hash
+	}
+	return hashes, nil
+}
+
+// TransactionRoot computes the Merkle root of the block transactions, nil for an empty block.
+func (b *Block) TransactionRoot() ([]byte, error) {
+	hashes, err := b.transactionHashes()
+	if err != nil {
+		return nil, err
+	}
+	return csmt.ListRoot(hashes), nil
+}
+
+// TxProof proves that a transaction is included into a block with a given tx root.
+type TxProof struct {
+	Index  uint64 // position of the transaction in the block
+	Total  uint64 // number of transactions in the block
+	Hashes [][]byte
+}
+
+// ProveTransaction returns the inclusion proof of the i-th transaction.
+func (b *Block) ProveTransaction(i int) (*TxProof, error) {
+	hashes, err := b.transactionHashes()
+	if err != nil {
+		return nil, err
+	}
+	proof, err := csmt.ListProof(hashes, []int{i})
+	if err != nil {
+		return nil, err
+	}
+	return &TxProof{uint64(i), uint64(len(hashes)), proof}, nil
+}
+
+// Verify checks that the transaction is included at the proof's index under the tx root.
+func (p *TxProof) Verify(txRoot []byte, tx *Transaction) error {
+	hash, err := tx.Hash()
+	if err != nil {
+		return err
+	}
+	if p.Total > MaxTransactions || p.Index >= p.Total {
+		return errors.New("Invalid transaction position")
+	}
+	return csmt.VerifyListProof(txRoot, int(p.Total), []int{int(p.Index)}, [][]byte{hash}, p.Hashes)
+}
+
+// CheckTransactionRoot checks the header's tx root against the block transactions.
+func (b *Block) CheckTransactionRoot() error {
+	root, err := b.TransactionRoot()
+	if err != nil {
+		return err
+	}
+	if bytes.Compare(root, b.Header.TxRoot) != 0 {
+		return errors.New("Transaction root does not match")
+	}
+	return nil
+}
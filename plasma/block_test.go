@@ -0,0 +1,63 @@
+package plasma
+
+import (
+	"bytes"
+	"testing"
+)
+
+func testBlock(t *testing.T) *Block {
+	tree, _, tx := testTree(t)
+	second := &Transaction{Inputs: tx.Inputs[1:], Outputs: tx.Outputs[:1]}
+	tx.Inputs = tx.Inputs[:1]
+	block := &Block{
+		Header:       BlockHeader{Number: 2, PrevHash: bytes.Repeat([]byte{0x01}, 32), SMTRoot: tree.RootHash(), Timestamp: 1541116800},
+		Transactions: []*Transaction{tx, second, tx},
+	}
+	root, err := block.TransactionRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Header.TxRoot = root
+	return block
+}
+
+func TestBlockEncoding(t *testing.T) {
+	block := testBlock(t)
+	encoded, err := block.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeBlock(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := block.Header.Hash()
+	second, _ := decoded.Header.Hash()
+	if bytes.Compare(first, second) != 0 {
+		t.Fatal("Header hash changed after a round trip")
+	}
+	if err := decoded.CheckTransactionRoot(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeBlock(encoded[:len(encoded)-3]); err == nil {
+		t.Fatal("Truncated block was decoded")
+	}
+}
+
+func TestTransactionInclusionProof(t *testing.T) {
+	block := testBlock(t)
+	proof, err := block.ProveTransaction(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proof.Verify(block.Header.TxRoot, block.Transactions[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := proof.Verify(block.Header.TxRoot, block.Transactions[0]); err == nil {
+		t.Fatal("Proof was accepted for another transaction")
+	}
+	proof.Index = 2
+	if err := proof.Verify(block.Header.TxRoot, block.Transactions[1]); err == nil {
+		t.Fatal("Proof was accepted for another position")
+	}
+}
@@ -0,0 +1,198 @@
+package plasma
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+// Deposit is a deposit event of the parent chain contract. Nonces are assigned by the
+// contract one after another starting from zero.
+type Deposit struct {
+	Nonce  uint64
+	Owner  []byte // depositor's public key in the same form as in outputs
+	Token  Token
+	Amount *big.Int
+}
+
+// Output returns the output minted for the deposit.
+func (d *Deposit) Output() Output {
+	return Output{d.Owner, append([]byte{}, d.Token[:]...), d.Amount}
+}
+
+// Transaction returns the transaction of a deposit block minting the deposit: it has no
+// inputs and a single output.
+func (d *Deposit) Transaction() *Transaction {
+	return &Transaction{Outputs: []Output{d.Output()}}
+}
+
+func (d *Deposit) check() error {
+	if d.Amount == nil || d.Amount.Sign() <= 0 {
+		return errors.New("Deposit amount must be positive")
+	}
+	out := d.Output()
+	_, err := out.Encode()
+	return err
+}
+
+// DepositFeed is a source of parent chain deposit events.
+type DepositFeed interface {
+	// Deposits returns up to limit deposits with nonces starting from the given one.
+	Deposits(from uint64, limit int) ([]Deposit, error)
+}
+
+// MemoryDepositFeed is an in-memory stand-in for the parent chain contract.
+type MemoryDepositFeed struct {
+	mu       sync.Mutex
+	deposits []Deposit
+}
+
+func NewMemoryDepositFeed() *MemoryDepositFeed {
+	return &MemoryDepositFeed{}
+}
+
+// Deposit records a deposit event like the contract would and returns it.
+func (f *MemoryDepositFeed) Deposit(owner []byte, token Token, amount *big.Int) Deposit {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := Deposit{uint64(len(f.deposits)), append([]byte{}, owner...), token, new(big.Int).Set(amount)}
+	f.deposits = append(f.deposits, d)
+	return d
+}
+
+func (f *MemoryDepositFeed) Deposits(from uint64, limit int) ([]Deposit, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if from > uint64(len(f.deposits)) {
+		return nil, errors.New("Deposit nonce is in the future")
+	}
+	to := uint64(len(f.deposits))
+	if limit >= 0 && to-from > uint64(limit) {
+		to = from + uint64(limit)
+	}
+	return append([]Deposit{}, f.deposits[from:to]...), nil
+}
+
+// DepositLedger tracks the next deposit nonce to be minted. Deposits are minted strictly
+// in the order of nonces, so a deposit can never be minted twice.
+type DepositLedger struct {
+	next uint64
+}
+
+// NewDepositLedger creates a ledger expecting the given nonce, zero for a new chain.
+func NewDepositLedger(next uint64) *DepositLedger {
+	return &DepositLedger{next}
+}
+
+// Next returns the nonce of the next deposit to mint.
+func (l *DepositLedger) Next() uint64 {
+	return l.next
+}
+
+// Check checks that the deposits are the next ones to mint.
+func (l *DepositLedger) Check(deposits []Deposit) error {
+	for i := range deposits {
+		if deposits[i].Nonce != l.next+uint64(i) {
+			return fmt.Errorf("Deposit %v has nonce %v, expected %v", i, deposits[i].Nonce, l.next+uint64(i))
+		}
+		if err := deposits[i].check(); err != nil {
+			return fmt.Errorf("Deposit %v: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Commit marks the deposits as minted.
+func (l *DepositLedger) Commit(deposits []Deposit) error {
+	if err := l.Check(deposits); err != nil {
+		return err
+	}
+	l.next += uint64(len(deposits))
+	return nil
+}
+
+// ProduceDeposits builds a deposit block minting the deposits, the i-th deposit becomes
+// the only output of the i-th transaction. The ledger is advanced only if the block is
+// produced.
+func (p *BlockProducer) ProduceDeposits(prev *BlockHeader, ledger *DepositLedger, deposits []Deposit, timestamp uint64) (*ProducedBlock, error) {
+	if len(deposits) == 0 || len(deposits) > MaxTransactions {
+		return nil, errors.New("Invalid number of deposits")
+	}
+	if err := ledger.Check(deposits); err != nil {
+		return nil, err
+	}
+	header, err := p.nextHeader(prev, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	header.Deposits, header.DepositNonce = uint64(len(deposits)), deposits[0].Nonce
+	block := &Block{Header: header, Transactions: make([]*Transaction, len(deposits))}
+	created := make(csmt.InsertionIndexes, len(deposits))
+	for i := range deposits {
+		block.Transactions[i] = deposits[i].Transaction()
+		value, _ := block.Transactions[i].Outputs[0].Encode()
+		created[i] = csmt.InsertedIndex{Index: csmt.UTXOIndex(header.Number, uint64(i), 0), Value: value}
+	}
+	produced, err := p.apply(block, nil, created)
+	if err != nil {
+		return nil, err
+	}
+	_ = ledger.Commit(deposits)
+	return produced, nil
+}
+
+// VerifyDepositBlock checks a deposit block against the deposits of the parent chain: it
+// has to mint exactly the next deposits of the ledger, which is advanced on success.
+// Failures are returned as a *VerificationError.
+func VerifyDepositBlock(prev *BlockHeader, block *Block, auditData []byte, ledger *DepositLedger, feed DepositFeed) error {
+	prevRoot, err := verifyHeaderLink(prev, block)
+	if err != nil {
+		return err
+	}
+	if len(block.Transactions) == 0 {
+		return blockFailure(MalformedTransaction, errors.New("Deposit block is empty"))
+	}
+	if block.Header.Deposits != uint64(len(block.Transactions)) || block.Header.DepositNonce != ledger.Next() {
+		return blockFailure(InvalidDeposit, errors.New("Header does not commit to the minted deposits"))
+	}
+	deposits, err := feed.Deposits(ledger.Next(), len(block.Transactions))
+	if err != nil {
+		return blockFailure(InvalidDeposit, err)
+	}
+	if len(deposits) != len(block.Transactions) {
+		return blockFailure(InvalidDeposit, errors.New("Block mints deposits that were not made"))
+	}
+	if err := ledger.Check(deposits); err != nil {
+		return blockFailure(InvalidDeposit, err)
+	}
+	if err := block.CheckTransactionRoot(); err != nil {
+		return blockFailure(InvalidTxRoot, err)
+	}
+	oldLeaves := make(map[uint64][]byte)
+	newLeaves := make(map[uint64][]byte)
+	for i, tx := range block.Transactions {
+		expected, _ := deposits[i].Transaction().Encode()
+		encoded, err := tx.Encode()
+		if err != nil {
+			return txFailure(MalformedTransaction, i, err)
+		}
+		if bytes.Compare(encoded, expected) != 0 {
+			return txFailure(InvalidDeposit, i, fmt.Errorf("Transaction does not mint deposit %v", deposits[i].Nonce))
+		}
+		value, _ := tx.Outputs[0].Encode()
+		index := csmt.UTXOIndex(block.Header.Number, uint64(i), 0)
+		oldLeaves[index], newLeaves[index] = nil, csmt.LeafHash(value)
+	}
+	audit, err := decodeAuditData(&block.Header, auditData)
+	if err != nil {
+		return err
+	}
+	if err := verifyTransition(prevRoot, &block.Header, audit, oldLeaves, newLeaves); err != nil {
+		return err
+	}
+	return ledger.Commit(deposits)
+}
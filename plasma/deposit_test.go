@@ -0,0 +1,86 @@
+package plasma
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+func TestDepositBlocks(t *testing.T) {
+	ed, secp := testSigners(t)
+	feed := NewMemoryDepositFeed()
+	feed.Deposit(ed.PubKey(), NativeToken, big.NewInt(100))
+	feed.Deposit(secp.PubKey(), Token{0x07}, big.NewInt(5))
+
+	producer := NewBlockProducer(csmt.NewCSMT(TreeHeight, true), ed)
+	operatorLedger, verifierLedger := NewDepositLedger(0), NewDepositLedger(0)
+	deposits, err := feed.Deposits(operatorLedger.Next(), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	produced, err := producer.ProduceDeposits(nil, operatorLedger, deposits, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if operatorLedger.Next() != 2 {
+		t.Fatal("Ledger was not advanced")
+	}
+	value := producer.Tree().Leaf(csmt.UTXOIndex(1, 1, 0))
+	expected := deposits[1].Output()
+	if encoded, _ := expected.Encode(); bytes.Compare(value, encoded) != 0 {
+		t.Fatal("Deposit was not minted")
+	}
+
+	if err := VerifyDepositBlock(nil, produced.Block, produced.AuditData, verifierLedger, feed); err != nil {
+		t.Fatal(err)
+	}
+	if verifierLedger.Next() != 2 {
+		t.Fatal("Verifier ledger was not advanced")
+	}
+	err = VerifyDepositBlock(nil, produced.Block, produced.AuditData, verifierLedger, feed)
+	expectFailure(t, err, InvalidDeposit, -1)
+
+	if _, err := producer.ProduceDeposits(&produced.Block.Header, operatorLedger, deposits, 0); err == nil {
+		t.Fatal("Deposits were minted twice")
+	}
+}
+
+func TestDepositBlockMintsOnlyDeposits(t *testing.T) {
+	ed, _ := testSigners(t)
+	feed := NewMemoryDepositFeed()
+	feed.Deposit(ed.PubKey(), NativeToken, big.NewInt(100))
+	deposits, _ := feed.Deposits(0, -1)
+	producer := NewBlockProducer(csmt.NewCSMT(TreeHeight, true), ed)
+	deposits[0].Amount = big.NewInt(1000)
+	produced, err := producer.ProduceDeposits(nil, NewDepositLedger(0), deposits, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = VerifyDepositBlock(nil, produced.Block, produced.AuditData, NewDepositLedger(0), feed)
+	expectFailure(t, err, InvalidDeposit, 0)
+}
+
+func TestDepositHeaderFields(t *testing.T) {
+	ed, _ := testSigners(t)
+	feed := NewMemoryDepositFeed()
+	feed.Deposit(ed.PubKey(), NativeToken, big.NewInt(100))
+	deposits, _ := feed.Deposits(0, -1)
+	producer := NewBlockProducer(csmt.NewCSMT(TreeHeight, true), ed)
+	produced, err := producer.ProduceDeposits(nil, NewDepositLedger(0), deposits, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &produced.Block.Header
+	if header.Deposits != 1 || header.DepositNonce != 0 {
+		t.Fatal("Header does not commit to the deposits")
+	}
+	err = VerifyBlock(nil, produced.Block, produced.AuditData)
+	expectFailure(t, err, InvalidDeposit, -1)
+
+	header.Deposits = 0
+	_ = header.Sign(ed)
+	err = VerifyDepositBlock(nil, produced.Block, produced.AuditData, NewDepositLedger(0), feed)
+	expectFailure(t, err, InvalidDeposit, -1)
+}
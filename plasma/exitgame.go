@@ -0,0 +1,444 @@
+package plasma
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+// Clock is the time source of the exit game, the parent chain block time in seconds.
+type Clock interface {
+	Now() uint64
+}
+
+// ManualClock is a clock that only moves when told to.
+type ManualClock struct {
+	now uint64
+}
+
+func NewManualClock(start uint64) *ManualClock {
+	return &ManualClock{start}
+}
+
+func (c *ManualClock) Now() uint64 {
+	return c.now
+}
+
+// Advance moves the clock forward by the given number of seconds.
+func (c *ManualClock) Advance(seconds uint64) {
+	c.now += seconds
+}
+
+// Exit is a pending claim to withdraw outputs to the parent chain.
+type Exit struct {
+	// Position is the exited output for a standard exit. For an in-flight exit it is the
+	// youngest input, which is also the exit priority as in MoreVP.
+	Position uint64
+	Outputs  []Output     // outputs paid out when the exit is finalized
+	Tx       *Transaction // the exited transaction, nil for a standard exit
+	Started  uint64
+
+	key     string
+	seq     uint64
+	removed bool
+}
+
+// InFlight tells whether the exit is an in-flight exit.
+func (e *Exit) InFlight() bool {
+	return e.Tx != nil
+}
+
+// exitQueue orders exits by priority: older positions go first, then older exits.
+type exitQueue []*Exit
+
+func (q exitQueue) Len() int { return len(q) }
+func (q exitQueue) Less(i, j int) bool {
+	if q[i].Position != q[j].Position {
+		return q[i].Position < q[j].Position
+	}
+	return q[i].seq < q[j].seq
+}
+func (q exitQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *exitQueue) Push(x interface{}) { *q = append(*q, x.(*Exit)) }
+func (q *exitQueue) Pop() interface{} {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
+
+// ExitGame plays the role of the parent chain contract: it stores submitted headers, accepts
+// exits and challenges and pays out exits that survive the challenge period.
+type ExitGame struct {
+	clock   Clock
+	period  uint64
+	headers []*BlockHeader
+	exits   map[string]*Exit
+	exited  map[uint64]bool // positions that were already paid out
+	queue   exitQueue
+	seq     uint64
+
+	deposits    DepositFeed
+	nextDeposit uint64 // nonce of the next deposit a deposit block has to mint
+}
+
+func NewExitGame(clock Clock, challengePeriod uint64, deposits DepositFeed) *ExitGame {
+	return &ExitGame{clock: clock, period: challengePeriod, exits: make(map[string]*Exit), exited: make(map[uint64]bool),
+		deposits: deposits}
+}
+
+// SubmitHeader stores the header of the next block, headers must link to each other.
+// A deposit block has to claim the next deposits made on the parent chain.
+func (g *ExitGame) SubmitHeader(h *BlockHeader) error {
+	var prevHash []byte
+	if len(g.headers) != 0 {
+		var err error
+		if prevHash, err = g.headers[len(g.headers)-1].Hash(); err != nil {
+			return err
+		}
+	}
+	if h.Number != uint64(len(g.headers))+1 || bytes.Compare(h.PrevHash, prevHash) != 0 {
+		return errors.New("Header does not follow the last submitted one")
+	}
+	if h.Deposits == 0 && h.DepositNonce != 0 {
+		return errors.New("Header has a deposit nonce but no deposits")
+	}
+	if h.Deposits != 0 {
+		if h.DepositNonce != g.nextDeposit || h.Deposits > MaxTransactions {
+			return errors.New("Header does not claim the next deposits")
+		}
+		deposits, err := g.deposits.Deposits(h.DepositNonce, int(h.Deposits))
+		if err != nil {
+			return err
+		}
+		if uint64(len(deposits)) != h.Deposits {
+			return errors.New("Header claims deposits that were not made")
+		}
+		g.nextDeposit += h.Deposits
+	}
+	g.headers = append(g.headers, h)
+	return nil
+}
+
+func (g *ExitGame) header(number uint64) (*BlockHeader, error) {
+	if number == 0 || number > uint64(len(g.headers)) {
+		return nil, fmt.Errorf("Block %v is not submitted", number)
+	}
+	return g.headers[number-1], nil
+}
+
+// prevRoot returns the root input proofs of a block's transactions are checked against.
+func (g *ExitGame) prevRoot(number uint64) ([]byte, error) {
+	if number == 1 {
+		return nil, nil
+	}
+	h, err := g.header(number - 1)
+	if err != nil {
+		return nil, err
+	}
+	return h.SMTRoot, nil
+}
+
+func standardExitKey(position uint64) string {
+	return fmt.Sprintf("output:%d", position)
+}
+
+func (g *ExitGame) start(e *Exit) {
+	e.Started = g.clock.Now()
+	e.seq = g.seq
+	g.seq++
+	g.exits[e.key] = e
+	heap.Push(&g.queue, e)
+}
+
+// StartExit starts a standard exit of an output present in the tree of the given block.
+func (g *ExitGame) StartExit(block uint64, position uint64, output Output, proof csmt.AuditNodes) (*Exit, error) {
+	h, err := g.header(block)
+	if err != nil {
+		return nil, err
+	}
+	if created, _, _ := csmt.SplitUTXOIndex(position); created > block {
+		return nil, errors.New("Output is younger than the block")
+	}
+	key := standardExitKey(position)
+	if _, exists := g.exits[key]; exists || g.exited[position] {
+		return nil, errors.New("Output is already exiting")
+	}
+	value, err := output.Encode()
+	if err != nil {
+		return nil, err
+	}
+	if err := proof.VefiryPath(TreeHeight, position, value, h.SMTRoot); err != nil {
+		return nil, err
+	}
+	e := &Exit{Position: position, Outputs: []Output{output}, key: key}
+	g.start(e)
+	return e, nil
+}
+
+// StartInFlightExit exits the outputs of a transaction that was not included into a block
+// or whose block was withheld. Its inputs have to be valid in the tree of the given block.
+func (g *ExitGame) StartInFlightExit(block uint64, tx *Transaction) (*Exit, error) {
+	h, err := g.header(block)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Validate(h.SMTRoot); err != nil {
+		return nil, err
+	}
+	if err := tx.VerifySignatures(); err != nil {
+		return nil, err
+	}
+	hash, _ := tx.SigningHash()
+	key := "tx:" + string(hash)
+	if _, exists := g.exits[key]; exists {
+		return nil, errors.New("Transaction is already exiting")
+	}
+	e := &Exit{Outputs: append([]Output{}, tx.Outputs...), Tx: tx, key: key}
+	for i := range tx.Inputs {
+		if g.exited[tx.Inputs[i].Position] {
+			return nil, errors.New("Input was already exited")
+		}
+		if tx.Inputs[i].Position > e.Position {
+			e.Position = tx.Inputs[i].Position
+		}
+	}
+	g.start(e)
+	return e, nil
+}
+
+func (g *ExitGame) challengeable(e *Exit) error {
+	if e == nil || e.removed {
+		return errors.New("No such exit")
+	}
+	if g.clock.Now() >= e.Started+g.period {
+		return errors.New("Challenge period is over")
+	}
+	return nil
+}
+
+func (g *ExitGame) cancel(e *Exit) {
+	e.removed = true
+	delete(g.exits, e.key)
+}
+
+// includedTransaction checks that the transaction is included into a submitted block.
+func (g *ExitGame) includedTransaction(block uint64, tx *Transaction, proof *TxProof) error {
+	h, err := g.header(block)
+	if err != nil {
+		return err
+	}
+	return proof.Verify(h.TxRoot, tx)
+}
+
+// ChallengeSpend cancels an exit with a transaction included into the given block that
+// spends the exited output or, for an in-flight exit, competes for one of its inputs.
+// The spending input must name the exited output, be valid in the previous block's tree
+// and be signed by the owner.
+func (g *ExitGame) ChallengeSpend(e *Exit, block uint64, spending *Transaction, proof *TxProof) error {
+	if err := g.challengeable(e); err != nil {
+		return err
+	}
+	if err := g.includedTransaction(block, spending, proof); err != nil {
+		return err
+	}
+	prevRoot, err := g.prevRoot(block)
+	if err != nil {
+		return err
+	}
+	hash, err := spending.SigningHash()
+	if err != nil {
+		return err
+	}
+	// the exited outputs by position, a spend has to name the same output
+	spent := make(map[uint64][]byte)
+	if e.InFlight() {
+		exitHash, _ := e.Tx.SigningHash()
+		if bytes.Compare(hash, exitHash) == 0 {
+			return errors.New("Transaction does not compete with the exit")
+		}
+		for i := range e.Tx.Inputs {
+			if spent[e.Tx.Inputs[i].Position], err = e.Tx.Inputs[i].Spent.Encode(); err != nil {
+				return err
+			}
+		}
+	} else if spent[e.Position], err = e.Outputs[0].Encode(); err != nil {
+		return err
+	}
+	for i := range spending.Inputs {
+		in := &spending.Inputs[i]
+		exited, exists := spent[in.Position]
+		if !exists {
+			continue
+		}
+		value, err := in.Spent.Encode()
+		if err != nil {
+			return fmt.Errorf("Input %v: %v", i, err)
+		}
+		if bytes.Compare(value, exited) != 0 {
+			return fmt.Errorf("Input %v spends another output", i)
+		}
+		if err := in.Proof.VefiryPath(TreeHeight, in.Position, value, prevRoot); err != nil {
+			return fmt.Errorf("Input %v: %v", i, err)
+		}
+		if err := VerifySignature(in.Spent.PubKey, hash, in.Signature); err != nil {
+			return fmt.Errorf("Input %v: %v", i, err)
+		}
+		g.cancel(e)
+		return nil
+	}
+	return errors.New("Transaction does not spend the exit")
+}
+
+// creatingTransaction checks that the transaction is included at the exited output's
+// position and creates the exited output, it returns the output's block.
+func (g *ExitGame) creatingTransaction(e *Exit, creating *Transaction, proof *TxProof) (uint64, error) {
+	block, txIndex, outIndex := csmt.SplitUTXOIndex(e.Position)
+	if proof.Index != txIndex {
+		return 0, errors.New("Transaction is not the one creating the output")
+	}
+	if err := g.includedTransaction(block, creating, proof); err != nil {
+		return 0, err
+	}
+	if outIndex >= uint64(len(creating.Outputs)) {
+		return 0, errors.New("Transaction does not create the output")
+	}
+	created, err := creating.Outputs[outIndex].Encode()
+	if err != nil {
+		return 0, err
+	}
+	exited, err := e.Outputs[0].Encode()
+	if err != nil {
+		return 0, err
+	}
+	if bytes.Compare(created, exited) != 0 {
+		return 0, errors.New("Transaction does not create the output")
+	}
+	return block, nil
+}
+
+// ChallengeInvalidHistory cancels a standard exit of an output created by a transaction
+// with an input that was not in the previous block's tree. Either the input's own proof
+// fails, or the absence proof shows the input's leaf was empty.
+func (g *ExitGame) ChallengeInvalidHistory(e *Exit, creating *Transaction, proof *TxProof, input int, absence csmt.AuditNodes) error {
+	if err := g.challengeable(e); err != nil {
+		return err
+	}
+	if e.InFlight() {
+		return errors.New("In-flight exits are checked when started")
+	}
+	block, err := g.creatingTransaction(e, creating, proof)
+	if err != nil {
+		return err
+	}
+	if input < 0 || input >= len(creating.Inputs) {
+		return errors.New("No such input")
+	}
+	prevRoot, err := g.prevRoot(block)
+	if err != nil {
+		return err
+	}
+	in := &creating.Inputs[input]
+	if absence != nil {
+		if err := absence.VerifyAbsence(TreeHeight, in.Position, prevRoot); err != nil {
+			return err
+		}
+	} else {
+		value, err := in.Spent.Encode()
+		if err != nil {
+			return err
+		}
+		if in.Proof.VefiryPath(TreeHeight, in.Position, value, prevRoot) == nil {
+			return errors.New("Input is valid")
+		}
+	}
+	// the leaf was shown empty or the included proof was shown wrong
+	g.cancel(e)
+	return nil
+}
+
+// ChallengeInvalidMint cancels a standard exit of an output created out of nothing: by a
+// transaction without inputs outside of a deposit block, or by a transaction of a deposit
+// block that does not mint the parent chain deposit it claims.
+func (g *ExitGame) ChallengeInvalidMint(e *Exit, creating *Transaction, proof *TxProof) error {
+	if err := g.challengeable(e); err != nil {
+		return err
+	}
+	if e.InFlight() {
+		return errors.New("In-flight exits are checked when started")
+	}
+	block, err := g.creatingTransaction(e, creating, proof)
+	if err != nil {
+		return err
+	}
+	h, err := g.header(block)
+	if err != nil {
+		return err
+	}
+	if h.Deposits == 0 {
+		if len(creating.Inputs) != 0 {
+			return errors.New("Transaction does not mint")
+		}
+	} else if proof.Index < h.Deposits {
+		deposits, err := g.deposits.Deposits(h.DepositNonce+proof.Index, 1)
+		if err != nil {
+			return err
+		}
+		encoded, err := creating.Encode()
+		if err != nil {
+			return err
+		}
+		if len(deposits) == 1 {
+			expected, _ := deposits[0].Transaction().Encode()
+			if bytes.Compare(encoded, expected) == 0 {
+				return errors.New("Transaction mints a deposit")
+			}
+		}
+	}
+	g.cancel(e)
+	return nil
+}
+
+// Finalize pays out exits in the priority order. Processing stops at the first exit
+// whose challenge period is not over yet, so younger exits never jump the queue. Every
+// output is paid out once: an exit of an output, or of a transaction spending an output,
+// that an earlier exit already paid out is cancelled.
+func (g *ExitGame) Finalize() []*Exit {
+	var finalized []*Exit
+	for g.queue.Len() != 0 {
+		e := g.queue[0]
+		if e.removed {
+			heap.Pop(&g.queue)
+			continue
+		}
+		if g.clock.Now() < e.Started+g.period {
+			break
+		}
+		heap.Pop(&g.queue)
+		delete(g.exits, e.key)
+		claimed := []uint64{e.Position}
+		if e.InFlight() {
+			claimed = claimed[:0]
+			for i := range e.Tx.Inputs {
+				claimed = append(claimed, e.Tx.Inputs[i].Position)
+			}
+		}
+		conflicting := false
+		for _, position := range claimed {
+			conflicting = conflicting || g.exited[position]
+		}
+		if conflicting {
+			// an exit of the same output was paid out first
+			e.removed = true
+			continue
+		}
+		for _, position := range claimed {
+			g.exited[position] = true
+		}
+		finalized = append(finalized, e)
+	}
+	return finalized
+}
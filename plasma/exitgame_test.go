@@ -0,0 +1,351 @@
+package plasma
+
+import (
+	"math/big"
+	"testing"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+const testChallengePeriod = 7 * 24 * 3600
+
+type testChain struct {
+	game     *ExitGame
+	clock    *ManualClock
+	producer *BlockProducer
+	feed     *MemoryDepositFeed
+	headers  []*BlockHeader
+}
+
+// newTestChain starts a chain with a deposit block: 100 for the ed25519 signer and 50
+// for the secp256k1 signer.
+func newTestChain(t *testing.T) *testChain {
+	ed, secp := testSigners(t)
+	clock := NewManualClock(1541116800)
+	feed := NewMemoryDepositFeed()
+	c := &testChain{NewExitGame(clock, testChallengePeriod, feed), clock, NewBlockProducer(csmt.NewCSMT(TreeHeight, true), ed), feed, nil}
+	feed.Deposit(ed.PubKey(), NativeToken, big.NewInt(100))
+	feed.Deposit(secp.PubKey(), NativeToken, big.NewInt(50))
+	deposits, _ := feed.Deposits(0, -1)
+	produced, err := c.producer.ProduceDeposits(nil, NewDepositLedger(0), deposits, clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.submit(t, produced.Block)
+	return c
+}
+
+func (c *testChain) submit(t *testing.T, block *Block) {
+	if err := c.game.SubmitHeader(&block.Header); err != nil {
+		t.Fatal(err)
+	}
+	c.headers = append(c.headers, &block.Header)
+}
+
+func (c *testChain) last() *BlockHeader {
+	return c.headers[len(c.headers)-1]
+}
+
+func (c *testChain) output(position uint64) Output {
+	out, _ := DecodeOutput(c.producer.Tree().Leaf(position))
+	return *out
+}
+
+// spend builds a transaction moving the ed25519 signer's deposit.
+func (c *testChain) spend(t *testing.T, amounts ...int64) *Transaction {
+	ed, secp := testSigners(t)
+	position := csmt.UTXOIndex(1, 0, 0)
+	spent := c.output(position)
+	tx := &Transaction{Inputs: []Input{{Position: position, Spent: &spent, Proof: c.producer.Tree().Prove(position)}}}
+	for _, amount := range amounts {
+		out := Output{secp.PubKey(), make([]byte, MetadataLength), big.NewInt(amount)}
+		tx.Outputs = append(tx.Outputs, out)
+	}
+	if err := tx.SignInput(0, ed); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func (c *testChain) produce(t *testing.T, txs ...*Transaction) *Block {
+	produced, err := c.producer.Produce(c.last(), txs, c.clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.submit(t, produced.Block)
+	return produced.Block
+}
+
+func TestExitFinalization(t *testing.T) {
+	c := newTestChain(t)
+	c.produce(t, c.spend(t, 60, 40))
+	young := csmt.UTXOIndex(2, 0, 1)
+	youngExit, err := c.game.StartExit(2, young, c.output(young), c.producer.Tree().Prove(young))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.clock.Advance(3600)
+	old := csmt.UTXOIndex(1, 1, 0)
+	oldExit, err := c.game.StartExit(2, old, c.output(old), c.producer.Tree().Prove(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.game.StartExit(2, old, c.output(old), c.producer.Tree().Prove(old)); err == nil {
+		t.Fatal("Output is exiting twice")
+	}
+
+	c.clock.Advance(testChallengePeriod - 1)
+	if finalized := c.game.Finalize(); len(finalized) != 0 {
+		t.Fatal("Exit with a higher priority was jumped over")
+	}
+	c.clock.Advance(3600)
+	finalized := c.game.Finalize()
+	if len(finalized) != 2 || finalized[0] != oldExit || finalized[1] != youngExit {
+		t.Fatal("Exits are not finalized in the priority order")
+	}
+	if _, err := c.game.StartExit(2, old, c.output(old), c.producer.Tree().Prove(old)); err == nil {
+		t.Fatal("Output was exited twice")
+	}
+}
+
+func TestExitSpendChallenge(t *testing.T) {
+	c := newTestChain(t)
+	position := csmt.UTXOIndex(1, 0, 0)
+	exit, err := c.game.StartExit(1, position, c.output(position), c.producer.Tree().Prove(position))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := c.produce(t, c.spend(t, 100))
+	proof, _ := block.ProveTransaction(0)
+	if err := c.game.ChallengeSpend(exit, 2, block.Transactions[0], proof); err != nil {
+		t.Fatal(err)
+	}
+	c.clock.Advance(testChallengePeriod)
+	if len(c.game.Finalize()) != 0 {
+		t.Fatal("Challenged exit was finalized")
+	}
+}
+
+func TestInFlightExitChallenge(t *testing.T) {
+	c := newTestChain(t)
+	withheld := c.spend(t, 90)
+	exit, err := c.game.StartInFlightExit(1, withheld)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exit.Position != csmt.UTXOIndex(1, 0, 0) {
+		t.Fatal("In-flight exit has invalid priority")
+	}
+	block := c.produce(t, withheld)
+	proof, _ := block.ProveTransaction(0)
+	if err := c.game.ChallengeSpend(exit, 2, withheld, proof); err == nil {
+		t.Fatal("Exit was challenged with the exited transaction itself")
+	}
+
+	c = newTestChain(t)
+	exit, _ = c.game.StartInFlightExit(1, c.spend(t, 90))
+	block = c.produce(t, c.spend(t, 80))
+	proof, _ = block.ProveTransaction(0)
+	if err := c.game.ChallengeSpend(exit, 2, block.Transactions[0], proof); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInvalidHistoryChallenge(t *testing.T) {
+	c := newTestChain(t)
+	// the operator includes a transaction spending an output that never existed
+	ed, _ := testSigners(t)
+	missing := csmt.UTXOIndex(1, 7, 0)
+	forged := Output{ed.PubKey(), make([]byte, MetadataLength), big.NewInt(1000)}
+	absence := c.producer.Tree().Prove(missing)
+	tx := &Transaction{
+		Inputs:  []Input{{Position: missing, Spent: &forged, Proof: absence}},
+		Outputs: []Output{forged},
+	}
+	_ = tx.SignInput(0, ed)
+	header, err := c.producer.nextHeader(c.last(), c.clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, _ := forged.Encode()
+	produced, err := c.producer.apply(&Block{Header: header, Transactions: []*Transaction{tx}}, nil,
+		csmt.InsertionIndexes{{Index: csmt.UTXOIndex(2, 0, 0), Value: value}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.submit(t, produced.Block)
+
+	position := csmt.UTXOIndex(2, 0, 0)
+	proof, _ := produced.Block.ProveTransaction(0)
+	for _, absenceProof := range []csmt.AuditNodes{absence, nil} {
+		exit, err := c.game.StartExit(2, position, forged, c.producer.Tree().Prove(position))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.game.ChallengeInvalidHistory(exit, tx, proof, 0, absenceProof); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deposit := csmt.UTXOIndex(1, 1, 0)
+	exit, _ := c.game.StartExit(2, deposit, c.output(deposit), c.producer.Tree().Prove(deposit))
+	c.clock.Advance(testChallengePeriod)
+	if err := c.game.ChallengeInvalidHistory(exit, tx, proof, 0, absence); err == nil {
+		t.Fatal("Challenge was accepted after the challenge period")
+	}
+}
+
+func TestInvalidMintChallenge(t *testing.T) {
+	c := newTestChain(t)
+	ed, _ := testSigners(t)
+	// the operator mints an output with a transaction block claiming no deposits
+	minted := Output{ed.PubKey(), make([]byte, MetadataLength), big.NewInt(1000)}
+	tx := &Transaction{Outputs: []Output{minted}}
+	header, err := c.producer.nextHeader(c.last(), c.clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, _ := minted.Encode()
+	position := csmt.UTXOIndex(2, 0, 0)
+	produced, err := c.producer.apply(&Block{Header: header, Transactions: []*Transaction{tx}}, nil,
+		csmt.InsertionIndexes{{Index: position, Value: value}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.submit(t, produced.Block)
+
+	proof, _ := produced.Block.ProveTransaction(0)
+	exit, err := c.game.StartExit(2, position, minted, c.producer.Tree().Prove(position))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.game.ChallengeInvalidMint(exit, tx, proof); err != nil {
+		t.Fatal(err)
+	}
+
+	deposit := csmt.UTXOIndex(1, 1, 0)
+	exit, err = c.game.StartExit(2, deposit, c.output(deposit), c.producer.Tree().Prove(deposit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := Block{Header: *c.headers[0], Transactions: []*Transaction{nil, nil}}
+	for i := range block.Transactions {
+		deposits, _ := c.feed.Deposits(uint64(i), 1)
+		block.Transactions[i] = deposits[0].Transaction()
+	}
+	proof, _ = block.ProveTransaction(1)
+	if err := c.game.ChallengeInvalidMint(exit, block.Transactions[1], proof); err == nil {
+		t.Fatal("Challenge was accepted for a deposit")
+	}
+}
+
+func TestSubmitHeaderChecksDeposits(t *testing.T) {
+	c := newTestChain(t)
+	ed, _ := testSigners(t)
+	c.feed.Deposit(ed.PubKey(), NativeToken, big.NewInt(10))
+	deposits, _ := c.feed.Deposits(2, -1)
+	// claiming a deposit that was not made
+	deposits = append(deposits, Deposit{3, ed.PubKey(), NativeToken, big.NewInt(10)})
+	produced, err := c.producer.ProduceDeposits(c.last(), NewDepositLedger(2), deposits, c.clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.game.SubmitHeader(&produced.Block.Header); err == nil {
+		t.Fatal("Header claiming missing deposits was accepted")
+	}
+	// minting the first deposit again
+	c = newTestChain(t)
+	deposits, _ = c.feed.Deposits(0, 1)
+	produced, err = c.producer.ProduceDeposits(c.last(), NewDepositLedger(0), deposits, c.clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.game.SubmitHeader(&produced.Block.Header); err == nil {
+		t.Fatal("Header minting a deposit twice was accepted")
+	}
+}
+
+func TestSpendChallengeChecksSpentOutput(t *testing.T) {
+	c := newTestChain(t)
+	position := csmt.UTXOIndex(1, 0, 0)
+	exit, err := c.game.StartExit(1, position, c.output(position), c.producer.Tree().Prove(position))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the operator includes a transaction naming the exited position with another output
+	tx := c.spend(t, 100)
+	other := c.output(csmt.UTXOIndex(1, 1, 0))
+	tx.Inputs[0].Spent = &other
+	ed, _ := testSigners(t)
+	_ = tx.SignInput(0, ed)
+	header, err := c.producer.nextHeader(c.last(), c.clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	produced, err := c.producer.apply(&Block{Header: header, Transactions: []*Transaction{tx}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.submit(t, produced.Block)
+	proof, _ := produced.Block.ProveTransaction(0)
+	if err := c.game.ChallengeSpend(exit, 2, tx, proof); err == nil {
+		t.Fatal("Exit was challenged with a spend of another output")
+	}
+}
+
+func TestInvalidHistoryChallengeNeedsProof(t *testing.T) {
+	c := newTestChain(t)
+	spentPosition := csmt.UTXOIndex(1, 0, 0)
+	// an absence proof of the spent deposit with its leaf moved to the other side
+	forged := append(csmt.AuditNodes{}, c.producer.Tree().Prove(spentPosition)...)
+	forged[TreeHeight].Value = nil
+	leaf := forged[TreeHeight-1].LeftSibling
+	forged[TreeHeight-1].LeftSibling, forged[TreeHeight-1].RightSibling = nil, leaf
+	tx := c.spend(t, 100)
+	block := c.produce(t, tx)
+	proof, _ := block.ProveTransaction(0)
+
+	position := csmt.UTXOIndex(2, 0, 0)
+	exit, err := c.game.StartExit(2, position, c.output(position), c.producer.Tree().Prove(position))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.game.ChallengeInvalidHistory(exit, tx, proof, 0, forged); err == nil {
+		t.Fatal("Valid exit was challenged with a forged absence proof")
+	}
+	if err := c.game.ChallengeInvalidHistory(exit, tx, proof, 0, nil); err == nil {
+		t.Fatal("Valid exit was challenged with a valid input proof")
+	}
+	c.clock.Advance(testChallengePeriod)
+	if len(c.game.Finalize()) != 1 {
+		t.Fatal("Valid exit was not finalized")
+	}
+}
+
+func TestConflictingExitsPayOnce(t *testing.T) {
+	c := newTestChain(t)
+	position := csmt.UTXOIndex(1, 0, 0)
+	if _, err := c.game.StartExit(1, position, c.output(position), c.producer.Tree().Prove(position)); err != nil {
+		t.Fatal(err)
+	}
+	// two transactions that were never included spend the same deposit
+	for _, amount := range []int64{100, 99} {
+		if _, err := c.game.StartInFlightExit(1, c.spend(t, amount)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.clock.Advance(testChallengePeriod)
+	finalized := c.game.Finalize()
+	paid := new(big.Int)
+	for _, e := range finalized {
+		for _, out := range e.Outputs {
+			paid.Add(paid, out.Amount)
+		}
+	}
+	if len(finalized) != 1 || paid.Int64() != 100 {
+		t.Fatalf("Deposit of 100 was paid out as %v in %v exits", paid, len(finalized))
+	}
+	if _, err := c.game.StartInFlightExit(1, c.spend(t, 98)); err == nil {
+		t.Fatal("Exit of a paid out output was started")
+	}
+}
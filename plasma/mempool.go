@@ -0,0 +1,211 @@
+package plasma
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+// recentRoot is a root of a recent block with the audit set leading to it from the
+// root before.
+type recentRoot struct {
+	root  []byte
+	audit csmt.AuditNodes
+}
+
+type pooledTransaction struct {
+	tx  *Transaction
+	key string
+	fee *big.Int // fee in the native token
+	seq uint64
+}
+
+// Mempool holds transactions waiting for a block. Users do not need the latest proofs:
+// transactions with proofs against any of the recent roots are accepted and their proofs
+// are brought up to date with the operator's tree, which happens again after every block.
+type Mempool struct {
+	tree    *csmt.CSMT
+	maxAge  int
+	recent  []recentRoot // oldest first, the last one is the current root
+	pending map[string]*pooledTransaction
+	spends  map[uint64]string // input position to the transaction spending it
+	seq     uint64
+}
+
+// NewMempool creates a pool on top of the operator's tree. Proofs against up to maxAge
+// previous roots are accepted.
+func NewMempool(tree *csmt.CSMT, maxAge int) *Mempool {
+	return &Mempool{
+		tree:    tree,
+		maxAge:  maxAge,
+		recent:  []recentRoot{{tree.RootHash(), nil}},
+		pending: make(map[string]*pooledTransaction),
+		spends:  make(map[uint64]string),
+	}
+}
+
+// Len returns the number of pending transactions.
+func (m *Mempool) Len() int {
+	return len(m.pending)
+}
+
+func (m *Mempool) rootAge(root []byte) int {
+	for i := len(m.recent) - 1; i >= 0; i-- {
+		if bytes.Compare(m.recent[i].root, root) == 0 {
+			return len(m.recent) - 1 - i
+		}
+	}
+	return -1
+}
+
+// refreshProof brings the proof of the input up to date. The proof is rolled forward with
+// the audit sets of the blocks after its root, and if that does not work it is taken from
+// the tree directly. An error means the output is not in the tree anymore.
+func (m *Mempool) refreshProof(in *Input) error {
+	value, err := in.Spent.Encode()
+	if err != nil {
+		return err
+	}
+	current := m.recent[len(m.recent)-1].root
+	if len(in.Proof) != 0 {
+		if age := m.rootAge(in.Proof[0].Value); age > 0 {
+			proof := in.Proof
+			for _, r := range m.recent[len(m.recent)-age:] {
+				if proof, err = proof.UpdateProofImproved(in.Position, r.audit); err != nil {
+					break
+				}
+			}
+			if err == nil && proof.VefiryPath(TreeHeight, in.Position, value, current) == nil {
+				in.Proof = proof
+				return nil
+			}
+		}
+	}
+	if in.Proof.VefiryPath(TreeHeight, in.Position, value, current) == nil {
+		return nil
+	}
+	proof := m.tree.Prove(in.Position)
+	if err := proof.VefiryPath(TreeHeight, in.Position, value, current); err != nil {
+		return errors.New("Output is not in the tree")
+	}
+	in.Proof = proof
+	return nil
+}
+
+// Add admits a transaction. Every input proof must be against a recent root, signatures and
+// values must be valid, the inputs must still be unspent and not spent by another pending
+// transaction.
+func (m *Mempool) Add(tx *Transaction) error {
+	if len(tx.Inputs) == 0 {
+		return errors.New("Transaction has no inputs")
+	}
+	if err := tx.VerifySignatures(); err != nil {
+		return err
+	}
+	fees, err := tx.Fees()
+	if err != nil {
+		return err
+	}
+	hash, err := tx.SigningHash()
+	if err != nil {
+		return err
+	}
+	key := string(hash)
+	if _, exists := m.pending[key]; exists {
+		return errors.New("Transaction is already pending")
+	}
+	pooled := &Transaction{Inputs: append([]Input{}, tx.Inputs...), Outputs: tx.Outputs}
+	for i := range pooled.Inputs {
+		in := &pooled.Inputs[i]
+		if len(in.Proof) == 0 || m.rootAge(in.Proof[0].Value) < 0 {
+			return fmt.Errorf("Input %v: proof is not against a recent root", i)
+		}
+		value, _ := in.Spent.Encode()
+		if err := in.Proof.VefiryPath(TreeHeight, in.Position, value, in.Proof[0].Value); err != nil {
+			return fmt.Errorf("Input %v: %v", i, err)
+		}
+		if _, exists := m.spends[in.Position]; exists {
+			return fmt.Errorf("Input %v conflicts with a pending transaction", i)
+		}
+		if err := m.refreshProof(in); err != nil {
+			return fmt.Errorf("Input %v: %v", i, err)
+		}
+	}
+	if err := pooled.Validate(m.recent[len(m.recent)-1].root); err != nil {
+		return err
+	}
+	fee := fees[NativeToken]
+	if fee == nil {
+		fee = new(big.Int)
+	}
+	p := &pooledTransaction{pooled, key, fee, m.seq}
+	m.seq++
+	m.pending[key] = p
+	for i := range pooled.Inputs {
+		m.spends[pooled.Inputs[i].Position] = key
+	}
+	return nil
+}
+
+func (m *Mempool) remove(p *pooledTransaction) {
+	delete(m.pending, p.key)
+	for i := range p.tx.Inputs {
+		if m.spends[p.tx.Inputs[i].Position] == p.key {
+			delete(m.spends, p.tx.Inputs[i].Position)
+		}
+	}
+}
+
+// Select returns up to max transactions with the highest native token fee first, earlier
+// transactions go first among equal fees. Proofs are against the current root.
+func (m *Mempool) Select(max int) []*Transaction {
+	sorted := make([]*pooledTransaction, 0, len(m.pending))
+	for _, p := range m.pending {
+		sorted = append(sorted, p)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if c := sorted[i].fee.Cmp(sorted[j].fee); c != 0 {
+			return c > 0
+		}
+		return sorted[i].seq < sorted[j].seq
+	})
+	if max >= 0 && len(sorted) > max {
+		sorted = sorted[:max]
+	}
+	txs := make([]*Transaction, len(sorted))
+	for i, p := range sorted {
+		txs[i] = p.tx
+	}
+	return txs
+}
+
+// BlockProduced updates the pool after the block was applied to the tree: included
+// transactions are removed, the ones spending outputs that are gone are dropped and the
+// proofs of the rest are refreshed. It returns the number of dropped transactions.
+func (m *Mempool) BlockProduced(produced *ProducedBlock) int {
+	m.recent = append(m.recent, recentRoot{produced.Block.Header.SMTRoot, produced.Audit})
+	if len(m.recent) > m.maxAge+1 {
+		m.recent = m.recent[len(m.recent)-m.maxAge-1:]
+	}
+	for _, tx := range produced.Block.Transactions {
+		hash, _ := tx.SigningHash()
+		if p, exists := m.pending[string(hash)]; exists {
+			m.remove(p)
+		}
+	}
+	dropped := 0
+	for _, p := range m.pending {
+		for i := range p.tx.Inputs {
+			if err := m.refreshProof(&p.tx.Inputs[i]); err != nil {
+				m.remove(p)
+				dropped++
+				break
+			}
+		}
+	}
+	return dropped
+}
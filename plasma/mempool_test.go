@@ -0,0 +1,106 @@
+package plasma
+
+import (
+	"math/big"
+	"testing"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+// spendSecp builds a transaction moving the secp256k1 signer's deposit.
+func (c *testChain) spendSecp(t *testing.T, amount int64) *Transaction {
+	_, secp := testSigners(t)
+	position := csmt.UTXOIndex(1, 1, 0)
+	spent := c.output(position)
+	tx := &Transaction{
+		Inputs:  []Input{{Position: position, Spent: &spent, Proof: c.producer.Tree().Prove(position)}},
+		Outputs: []Output{{secp.PubKey(), make([]byte, MetadataLength), big.NewInt(amount)}},
+	}
+	if err := tx.SignInput(0, secp); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func (c *testChain) producePool(t *testing.T, pool *Mempool) *ProducedBlock {
+	produced, err := c.producer.Produce(c.last(), pool.Select(-1), c.clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.submit(t, produced.Block)
+	pool.BlockProduced(produced)
+	return produced
+}
+
+func TestMempoolRefreshesProofs(t *testing.T) {
+	c := newTestChain(t)
+	pool := NewMempool(c.producer.Tree(), 4)
+	stale := c.spendSecp(t, 50)
+	if err := pool.Add(c.spend(t, 100)); err != nil {
+		t.Fatal(err)
+	}
+	c.producePool(t, pool)
+	if pool.Len() != 0 {
+		t.Fatal("Included transaction is still pending")
+	}
+	if err := pool.Add(stale); err != nil {
+		t.Fatal(err)
+	}
+	produced := c.producePool(t, pool)
+	if len(produced.Block.Transactions) != 1 {
+		t.Fatal("Transaction with a stale proof was not included")
+	}
+	if err := VerifyBlock(c.headers[1], produced.Block, produced.AuditData); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMempoolRejectsConflicts(t *testing.T) {
+	c := newTestChain(t)
+	pool := NewMempool(c.producer.Tree(), 4)
+	if err := pool.Add(c.spend(t, 100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Add(c.spend(t, 90)); err == nil {
+		t.Fatal("Conflicting transaction was admitted")
+	}
+	conflicting := c.spend(t, 80)
+	pending, late := c.spendSecp(t, 50), c.spendSecp(t, 30)
+	if err := pool.Add(pending); err != nil {
+		t.Fatal(err)
+	}
+
+	// the block is built outside of the pool and spends the pending transaction's input
+	produced, err := c.producer.Produce(c.last(), []*Transaction{c.spendSecp(t, 40)}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped := pool.BlockProduced(produced); dropped != 1 || pool.Len() != 1 {
+		t.Fatal("Transaction spending a spent output was not dropped")
+	}
+	if err := pool.Add(late); err == nil {
+		t.Fatal("Double spend was admitted")
+	}
+	if err := pool.Add(conflicting); err == nil {
+		t.Fatal("Conflicting transaction was admitted after a block")
+	}
+}
+
+func TestMempoolOrdersByFee(t *testing.T) {
+	c := newTestChain(t)
+	pool := NewMempool(c.producer.Tree(), 4)
+	cheap, expensive := c.spend(t, 90), c.spendSecp(t, 30)
+	if err := pool.Add(cheap); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Add(expensive); err != nil {
+		t.Fatal(err)
+	}
+	selected := pool.Select(-1)
+	if len(selected) != 2 || selected[0].Outputs[0].Amount.Int64() != 30 {
+		t.Fatal("Transactions are not ordered by fee")
+	}
+	if len(pool.Select(1)) != 1 {
+		t.Fatal("Selection is not limited")
+	}
+}
@@ -0,0 +1,161 @@
+package plasma
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+// MaxBlockNumber is the last block number that fits into the 24 bit block prefix.
+const MaxBlockNumber = 1<<24 - 1
+
+// BlockProducer is the operator side of the chain: it owns the tree of unspent outputs and
+// turns transactions into signed blocks.
+type BlockProducer struct {
+	tree   *csmt.CSMT
+	signer Signer
+}
+
+// ProducedBlock is a signed block and the data clients need to update their proofs.
+type ProducedBlock struct {
+	Block     *Block
+	Audit     csmt.AuditNodes // nodes changed by the block in the canonical order
+	AuditData []byte          // encoded audit, committed to by the header
+	Fees      Fees            // fees of all transactions in the block
+}
+
+// NewBlockProducer creates a producer on top of a tree that is in sync with the last block.
+// The tree has to store its leaves, spent outputs are checked against them.
+func NewBlockProducer(tree *csmt.CSMT, signer Signer) *BlockProducer {
+	return &BlockProducer{tree, signer}
+}
+
+// Tree returns the tree of unspent outputs after the last produced block.
+func (p *BlockProducer) Tree() *csmt.CSMT {
+	return p.tree
+}
+
+// blockChanges checks the transactions against the previous root and the leaves of the
+// tree and collects the outputs they spend and create together with the fees. Outputs of
+// the i-th transaction are placed under the block's prefix at position i.
+func (p *BlockProducer) blockChanges(number uint64, prevRoot []byte, txs []*Transaction) (csmt.InsertionIndexes, csmt.InsertionIndexes, Fees, error) {
+	var spent, created csmt.InsertionIndexes
+	fees := make(Fees)
+	seen := make(map[uint64]int)
+	for i, tx := range txs {
+		if err := tx.Validate(prevRoot); err != nil {
+			return nil, nil, nil, fmt.Errorf("Transaction %v: %v", i, err)
+		}
+		if err := tx.VerifySignatures(); err != nil {
+			return nil, nil, nil, fmt.Errorf("Transaction %v: %v", i, err)
+		}
+		txFees, _ := tx.Fees()
+		fees.Add(txFees)
+		for j := range tx.Inputs {
+			in := &tx.Inputs[j]
+			if other, exists := seen[in.Position]; exists {
+				return nil, nil, nil, fmt.Errorf("Transaction %v spends an output already spent by transaction %v", i, other)
+			}
+			seen[in.Position] = i
+			value, _ := in.Spent.Encode()
+			// the leaf is the spent state, not the proof
+			if bytes.Compare(p.tree.Leaf(in.Position), value) != 0 {
+				return nil, nil, nil, fmt.Errorf("Transaction %v input %v does not spend the output at its position", i, j)
+			}
+			spent = append(spent, csmt.InsertedIndex{Index: in.Position, Value: value})
+		}
+		for j := range tx.Outputs {
+			value, _ := tx.Outputs[j].Encode()
+			created = append(created, csmt.InsertedIndex{Index: csmt.UTXOIndex(number, uint64(i), uint64(j)), Value: value})
+		}
+	}
+	sort.Sort(spent)
+	return spent, created, fees, nil
+}
+
+func deletionIndexes(leaves csmt.InsertionIndexes) csmt.DeletionIndexes {
+	indexes := make(csmt.DeletionIndexes, len(leaves))
+	for i := range leaves {
+		indexes[i] = leaves[i].Index
+	}
+	return indexes
+}
+
+// nextHeader starts the header of the block following prev, nil for the first block.
+func (p *BlockProducer) nextHeader(prev *BlockHeader, timestamp uint64) (BlockHeader, error) {
+	header := BlockHeader{Number: 1, Timestamp: timestamp}
+	var prevRoot []byte
+	if prev != nil {
+		prevHash, err := prev.Hash()
+		if err != nil {
+			return header, err
+		}
+		header.Number, header.PrevHash, prevRoot = prev.Number+1, prevHash, prev.SMTRoot
+	}
+	if header.Number > MaxBlockNumber {
+		return header, errors.New("Block number is out of range")
+	}
+	if bytes.Compare(p.tree.RootHash(), prevRoot) != 0 {
+		return header, errors.New("Tree is not in sync with the previous block")
+	}
+	if p.tree.BlockRoot(header.Number) != nil {
+		return header, errors.New("Tree already has outputs of this block")
+	}
+	return header, nil
+}
+
+// Produce builds the block following prev, applies it to the tree and signs the header.
+// prev is nil for the first block. If anything fails the tree is left untouched.
+func (p *BlockProducer) Produce(prev *BlockHeader, txs []*Transaction, timestamp uint64) (*ProducedBlock, error) {
+	if len(txs) > MaxTransactions {
+		return nil, errors.New("Too many transactions")
+	}
+	header, err := p.nextHeader(prev, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	var prevRoot []byte
+	if prev != nil {
+		prevRoot = prev.SMTRoot
+	}
+	spent, created, fees, err := p.blockChanges(header.Number, prevRoot, txs)
+	if err != nil {
+		return nil, err
+	}
+	produced, err := p.apply(&Block{Header: header, Transactions: txs}, spent, created)
+	if err != nil {
+		return nil, err
+	}
+	produced.Fees = fees
+	return produced, nil
+}
+
+// apply changes the tree and completes and signs the header.
+func (p *BlockProducer) apply(block *Block, spent, created csmt.InsertionIndexes) (*ProducedBlock, error) {
+	var err error
+	if block.Header.TxRoot, err = block.TransactionRoot(); err != nil {
+		return nil, err
+	}
+	deleted := p.tree.ApplyDeletes(deletionIndexes(spent))
+	inserted := p.tree.ApplyInserts(created)
+	audit := csmt.MergeAuditNodes(deleted, inserted)
+	block.Header.SMTRoot = p.tree.RootHash()
+	if block.Header.AuditCommitment, err = audit.Commitment(); err == nil {
+		err = block.Header.Sign(p.signer)
+	}
+	if err != nil {
+		p.rollback(spent, created)
+		return nil, err
+	}
+	return &ProducedBlock{Block: block, Audit: audit, AuditData: audit.Encode()}, nil
+}
+
+// rollback applies the inverse of a block. The tree only depends on the set of leaves, so
+// the previous root is restored exactly.
+func (p *BlockProducer) rollback(spent, created csmt.InsertionIndexes) {
+	p.tree.ApplyDeletes(deletionIndexes(created))
+	p.tree.ApplyInserts(spent)
+}
@@ -0,0 +1,143 @@
+package plasma
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+type failingSigner struct{ Signer }
+
+func (failingSigner) Sign(hash []byte) ([]byte, error) {
+	return nil, errors.New("Signer is offline")
+}
+
+// testProducer returns a producer whose tree holds outputs of block 1 owned by the test
+// signers, the header of block 1 and a signed transaction spending the first two outputs.
+func testProducer(t *testing.T) (*BlockProducer, *BlockHeader, *Transaction) {
+	ed, secp := testSigners(t)
+	_, outputs, tx := testTree(t)
+	outputs[0].PubKey, outputs[1].PubKey = ed.PubKey(), secp.PubKey()
+	tree := csmt.NewCSMT(TreeHeight, true)
+	var toInsert csmt.InsertionIndexes
+	for i := range outputs {
+		encoded, _ := outputs[i].Encode()
+		toInsert = append(toInsert, csmt.InsertedIndex{Index: csmt.UTXOIndex(1, uint64(i), 0), Value: encoded})
+	}
+	_ = tree.ApplyInserts(toInsert)
+	for i := range tx.Inputs {
+		tx.Inputs[i].Proof = tree.Prove(tx.Inputs[i].Position)
+	}
+	if err := tx.SignInput(0, ed); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.SignInput(1, secp); err != nil {
+		t.Fatal(err)
+	}
+	operator, _ := testSigners(t)
+	return NewBlockProducer(tree, operator), &BlockHeader{Number: 1, SMTRoot: tree.RootHash()}, tx
+}
+
+func TestBlockProducer(t *testing.T) {
+	producer, prev, tx := testProducer(t)
+	untouched := csmt.UTXOIndex(1, 2, 0)
+	oldProof := producer.Tree().Prove(untouched)
+	produced, err := producer.Produce(prev, []*Transaction{tx}, 1541116800)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &produced.Block.Header
+	if header.Number != 2 || bytes.Compare(header.SMTRoot, producer.Tree().RootHash()) != 0 {
+		t.Fatal("Header does not describe the new tree")
+	}
+	operator, _ := testSigners(t)
+	if err := header.VerifySignature(operator.PubKey()); err != nil {
+		t.Fatal(err)
+	}
+	if err := produced.Block.CheckTransactionRoot(); err != nil {
+		t.Fatal(err)
+	}
+	if producer.Tree().Leaf(tx.Inputs[0].Position) != nil {
+		t.Fatal("Spent output is still in the tree")
+	}
+	audit, err := csmt.DecodeAuditNodes(produced.AuditData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitment, _ := audit.Commitment()
+	if bytes.Compare(commitment, header.AuditCommitment) != 0 {
+		t.Fatal("Audit data does not match the header commitment")
+	}
+	newOutput := csmt.UTXOIndex(2, 0, 1)
+	value, _ := tx.Outputs[1].Encode()
+	if err := audit.FilterPath(TreeHeight, newOutput).VefiryPath(TreeHeight, newOutput, value, header.SMTRoot); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := oldProof.UpdateProofImproved(untouched, audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value = producer.Tree().Leaf(untouched)
+	if err := updated.VefiryPath(TreeHeight, untouched, value, header.SMTRoot); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlockProducerAtomic(t *testing.T) {
+	producer, prev, tx := testProducer(t)
+	root := producer.Tree().RootHash()
+	tx.Inputs[1].Signature[0] ^= 0xff
+	if _, err := producer.Produce(prev, []*Transaction{tx}, 0); err == nil {
+		t.Fatal("Block with a badly signed transaction was produced")
+	}
+	tx.Inputs[1].Signature[0] ^= 0xff
+	second := &Transaction{Inputs: []Input{tx.Inputs[0]}, Outputs: tx.Outputs[:1]}
+	ed, _ := testSigners(t)
+	if err := second.SignInput(0, ed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := producer.Produce(prev, []*Transaction{tx, second}, 0); err == nil {
+		t.Fatal("Block with a double spend was produced")
+	}
+	operator := producer.signer
+	producer.signer = failingSigner{operator}
+	if _, err := producer.Produce(prev, []*Transaction{tx}, 0); err == nil {
+		t.Fatal("Block was produced without a signature")
+	}
+	if bytes.Compare(producer.Tree().RootHash(), root) != 0 || producer.Tree().Leaf(tx.Inputs[0].Position) == nil {
+		t.Fatal("Failed block changed the tree")
+	}
+	producer.signer = operator
+	if _, err := producer.Produce(prev, []*Transaction{tx}, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// relabel moves the input to the empty position next to the spent output, swapping the
+// sides of the siblings so that only the tags of the node hashes tell the proofs apart.
+func relabel(in *Input) {
+	in.Position ^= 1
+	proof := append(csmt.AuditNodes{}, in.Proof...)
+	last := len(proof) - 1
+	proof[last].Index ^= 1
+	parent := proof[last-1]
+	proof[last-1].LeftSibling, proof[last-1].RightSibling = parent.RightSibling, parent.LeftSibling
+	in.Proof = proof
+}
+
+func TestBlockProducerChecksSpentLeaves(t *testing.T) {
+	producer, prev, tx := testProducer(t)
+	relabel(&tx.Inputs[0])
+	ed, secp := testSigners(t)
+	_ = tx.SignInput(0, ed)
+	_ = tx.SignInput(1, secp)
+	root := producer.Tree().RootHash()
+	if _, err := producer.Produce(prev, []*Transaction{tx}, 0); err == nil {
+		t.Fatal("Block spending an empty position was produced")
+	}
+	if bytes.Compare(producer.Tree().RootHash(), root) != 0 {
+		t.Fatal("Failed block changed the tree")
+	}
+}
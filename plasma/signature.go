@@ -0,0 +1,188 @@
+package plasma
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// Public keys in outputs are always 33 bytes long. A compressed secp256k1 key starts with
+// 0x02 or 0x03, an ed25519 key is prefixed with PubKeyTagEd25519.
+const (
+	PubKeyTagEd25519 = byte(0xed)
+
+	secp256k1SignatureLength = 65
+)
+
+// Signer signs the signing hash of a transaction on behalf of an output owner.
+type Signer interface {
+	// PubKey returns the key in the same form as it is stored in outputs.
+	PubKey() []byte
+	Sign(hash []byte) ([]byte, error)
+}
+
+// Verifier checks that the signature over the hash was made by the owner of the public key.
+type Verifier interface {
+	Verify(pubKey, hash, signature []byte) error
+}
+
+// Ed25519Signer signs with an ed25519 key.
+type Ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewEd25519Signer creates a signer from a 32 byte seed.
+func NewEd25519Signer(seed []byte) (*Ed25519Signer, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.New("Invalid ed25519 seed length")
+	}
+	return &Ed25519Signer{ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+func (s *Ed25519Signer) PubKey() []byte {
+	return append([]byte{PubKeyTagEd25519}, s.key.Public().(ed25519.PublicKey)...)
+}
+
+func (s *Ed25519Signer) Sign(hash []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, hash), nil
+}
+
+// Ed25519Verifier checks plain ed25519 signatures.
+type Ed25519Verifier struct{}
+
+func (Ed25519Verifier) Verify(pubKey, hash, signature []byte) error {
+	if len(pubKey) != PubKeyLength || pubKey[0] != PubKeyTagEd25519 {
+		return errors.New("Not an ed25519 public key")
+	}
+	if len(signature) != ed25519.SignatureSize || !ed25519.Verify(ed25519.PublicKey(pubKey[1:]), hash, signature) {
+		return errors.New("Invalid ed25519 signature")
+	}
+	return nil
+}
+
+// Secp256k1Signer produces Ethereum style signatures r||s||v with v being 27 or 28.
+type Secp256k1Signer struct {
+	key *btcec.PrivateKey
+}
+
+// NewSecp256k1Signer creates a signer from a 32 byte private key.
+func NewSecp256k1Signer(privateKey []byte) (*Secp256k1Signer, error) {
+	if len(privateKey) != 32 {
+		return nil, errors.New("Invalid secp256k1 private key length")
+	}
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), privateKey)
+	if key.D.Sign() == 0 || key.D.Cmp(btcec.S256().N) >= 0 {
+		return nil, errors.New("Invalid secp256k1 private key")
+	}
+	return &Secp256k1Signer{key}, nil
+}
+
+func (s *Secp256k1Signer) PubKey() []byte {
+	return s.key.PubKey().SerializeCompressed()
+}
+
+func (s *Secp256k1Signer) Sign(hash []byte) ([]byte, error) {
+	compact, err := btcec.SignCompact(btcec.S256(), s.key, hash, false)
+	if err != nil {
+		return nil, err
+	}
+	// btcec puts the recovery byte first, Ethereum puts it last
+	return append(compact[1:], compact[0]), nil
+}
+
+// Secp256k1Verifier recovers the signer's key from an Ethereum style signature and
+// compares it with the expected one.
+type Secp256k1Verifier struct{}
+
+var secp256k1HalfOrder = new(big.Int).Rsh(btcec.S256().N, 1)
+
+func (Secp256k1Verifier) Verify(pubKey, hash, signature []byte) error {
+	if len(pubKey) != PubKeyLength || (pubKey[0] != 0x02 && pubKey[0] != 0x03) {
+		return errors.New("Not a compressed secp256k1 public key")
+	}
+	if len(signature) != secp256k1SignatureLength {
+		return errors.New("Invalid secp256k1 signature length")
+	}
+	v := signature[64]
+	if v != 27 && v != 28 {
+		return errors.New("Invalid recovery id")
+	}
+	// same as Ethereum, only the lower half of s is accepted to avoid malleability
+	if new(big.Int).SetBytes(signature[32:64]).Cmp(secp256k1HalfOrder) > 0 {
+		return errors.New("Signature s value is too high")
+	}
+	compact := append([]byte{v}, signature[:64]...)
+	recovered, _, err := btcec.RecoverCompact(btcec.S256(), compact, hash)
+	if err != nil {
+		return err
+	}
+	if bytes.Compare(recovered.SerializeCompressed(), pubKey) != 0 {
+		return errors.New("Recovered key does not match the owner")
+	}
+	return nil
+}
+
+// VerifySignature picks the verifier by the kind of the public key.
+func VerifySignature(pubKey, hash, signature []byte) error {
+	if len(pubKey) != PubKeyLength {
+		return errors.New("Invalid public key length")
+	}
+	switch pubKey[0] {
+	case PubKeyTagEd25519:
+		return Ed25519Verifier{}.Verify(pubKey, hash, signature)
+	case 0x02, 0x03:
+		return Secp256k1Verifier{}.Verify(pubKey, hash, signature)
+	}
+	return errors.New("Unsupported public key kind")
+}
+
+// SigningHash is the hash owners sign. Proofs are rewritten by the operator when inputs
+// are refreshed against a newer root, so proofs and signatures are not part of it.
+func (tx *Transaction) SigningHash() ([]byte, error) {
+	encoded, err := tx.encode(false)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha512.Sum512_256(encoded)
+	return hash[:], nil
+}
+
+// SignInput signs the input, the signer must own the spent output.
+func (tx *Transaction) SignInput(i int, signer Signer) error {
+	if i < 0 || i >= len(tx.Inputs) {
+		return errors.New("No such input")
+	}
+	if tx.Inputs[i].Spent == nil || bytes.Compare(tx.Inputs[i].Spent.PubKey, signer.PubKey()) != 0 {
+		return errors.New("Signer does not own the spent output")
+	}
+	hash, err := tx.SigningHash()
+	if err != nil {
+		return err
+	}
+	signature, err := signer.Sign(hash)
+	if err != nil {
+		return err
+	}
+	tx.Inputs[i].Signature = signature
+	return nil
+}
+
+// VerifySignatures checks that every input is signed by the owner of the spent output.
+func (tx *Transaction) VerifySignatures() error {
+	hash, err := tx.SigningHash()
+	if err != nil {
+		return err
+	}
+	for i := range tx.Inputs {
+		in := &tx.Inputs[i]
+		if err := VerifySignature(in.Spent.PubKey, hash, in.Signature); err != nil {
+			return fmt.Errorf("Input %v: %v", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package plasma
+
+import (
+	"bytes"
+	"testing"
+)
+
+func testSigners(t *testing.T) (Signer, Signer) {
+	ed, err := NewEd25519Signer(bytes.Repeat([]byte{0x01}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secp, err := NewSecp256k1Signer(bytes.Repeat([]byte{0x02}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ed, secp
+}
+
+func TestSignatureSchemes(t *testing.T) {
+	ed, secp := testSigners(t)
+	hash := bytes.Repeat([]byte{0xaa}, 32)
+	for _, signer := range []Signer{ed, secp} {
+		signature, err := signer.Sign(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifySignature(signer.PubKey(), hash, signature); err != nil {
+			t.Fatal(err)
+		}
+		otherHash := bytes.Repeat([]byte{0xbb}, 32)
+		if err := VerifySignature(signer.PubKey(), otherHash, signature); err == nil {
+			t.Fatal("Signature was accepted for another hash")
+		}
+	}
+	signature, _ := secp.Sign(hash)
+	if signature[64] != 27 && signature[64] != 28 {
+		t.Fatal("Signature is not in the Ethereum form")
+	}
+	if err := VerifySignature(ed.PubKey(), hash, signature); err == nil {
+		t.Fatal("Signature was accepted for another key kind")
+	}
+}
+
+func TestTransactionSignatures(t *testing.T) {
+	ed, secp := testSigners(t)
+	tree, outputs, tx := testTree(t)
+	outputs[0].PubKey = ed.PubKey()
+	outputs[1].PubKey = secp.PubKey()
+	if err := tx.SignInput(0, secp); err == nil {
+		t.Fatal("Input was signed by a stranger")
+	}
+	if err := tx.SignInput(0, ed); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.SignInput(1, secp); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.VerifySignatures(); err != nil {
+		t.Fatal(err)
+	}
+	// proofs can be refreshed without invalidating signatures
+	tx.Inputs[0].Proof = tree.Prove(tx.Inputs[0].Position)
+	if err := tx.VerifySignatures(); err != nil {
+		t.Fatal("Refreshing a proof invalidated signatures")
+	}
+	tx.Outputs[0].Amount.SetInt64(26)
+	if err := tx.VerifySignatures(); err == nil {
+		t.Fatal("Modified transaction passed signature check")
+	}
+}
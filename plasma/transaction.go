@@ -0,0 +1,250 @@
+// Package plasma implements Plasma Compact chain objects on top of the compact sparse
+// Merkle tree of unspent outputs.
+package plasma
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+const (
+	// TreeHeight is the height of the global tree of outputs: 24 bits of block number,
+	// 20 bits of transaction number and 4 bits of output number.
+	TreeHeight = 48
+
+	PubKeyLength   = 33
+	MetadataLength = 20
+	AmountLength   = 32
+	OutputLength   = PubKeyLength + MetadataLength + AmountLength
+
+	MaxInputs  = 16
+	MaxOutputs = 16
+)
+
+// Output is an unspent output, its encoding pubkey||metadata||amount is the leaf value.
+type Output struct {
+	PubKey   []byte   // owner's public key, see signature.go for the supported kinds
+	Metadata []byte   // token identifier like an ERC20 address, zeroes for the native token
+	Amount   *big.Int // unsigned 256 bit amount
+}
+
+// Encode returns the leaf value of the output.
+func (o *Output) Encode() ([]byte, error) {
+	if len(o.PubKey) != PubKeyLength {
+		return nil, errors.New("Invalid public key length")
+	}
+	if len(o.Metadata) != MetadataLength {
+		return nil, errors.New("Invalid metadata length")
+	}
+	if o.Amount == nil || o.Amount.Sign() < 0 || o.Amount.BitLen() > AmountLength*8 {
+		return nil, errors.New("Amount is out of range")
+	}
+	encoded := make([]byte, OutputLength)
+	copy(encoded, o.PubKey)
+	copy(encoded[PubKeyLength:], o.Metadata)
+	o.Amount.FillBytes(encoded[PubKeyLength+MetadataLength:])
+	return encoded, nil
+}
+
+func DecodeOutput(data []byte) (*Output, error) {
+	if len(data) != OutputLength {
+		return nil, errors.New("Invalid output length")
+	}
+	o := new(Output)
+	o.PubKey = append([]byte{}, data[:PubKeyLength]...)
+	o.Metadata = append([]byte{}, data[PubKeyLength:PubKeyLength+MetadataLength]...)
+	o.Amount = new(big.Int).SetBytes(data[PubKeyLength+MetadataLength:])
+	return o, nil
+}
+
+// Input spends an output of the previous block's tree.
+type Input struct {
+	Position  uint64          // index of the spent output in the tree
+	Spent     *Output         // the spent output, needed to check the proof and the signature
+	Proof     csmt.AuditNodes // inclusion proof against the previous block's root
+	Signature []byte
+}
+
+// Transaction spends inputs and creates outputs.
+type Transaction struct {
+	Inputs  []Input
+	Outputs []Output
+}
+
+// Encoding layout, all integers are big endian:
+//
+//	number of inputs | inputs | number of outputs | outputs
+//	input: position (8 bytes) | spent output | proof length (4 bytes) | proof | signature length | signature
+//	output: pubkey | metadata | amount
+
+// Encode returns the canonical encoding of the transaction.
+func (tx *Transaction) Encode() ([]byte, error) {
+	return tx.encode(true)
+}
+
+// encode optionally leaves out the proofs and signatures.
+func (tx *Transaction) encode(withWitness bool) ([]byte, error) {
+	if len(tx.Inputs) > MaxInputs || len(tx.Outputs) > MaxOutputs {
+		return nil, errors.New("Too many inputs or outputs")
+	}
+	encoded := []byte{uint8(len(tx.Inputs))}
+	for i := range tx.Inputs {
+		in := &tx.Inputs[i]
+		if in.Spent == nil {
+			return nil, fmt.Errorf("Input %v has no spent output", i)
+		}
+		spent, err := in.Spent.Encode()
+		if err != nil {
+			return nil, fmt.Errorf("Input %v: %v", i, err)
+		}
+		position := make([]byte, 8)
+		binary.BigEndian.PutUint64(position, in.Position)
+		encoded = append(encoded, position...)
+		encoded = append(encoded, spent...)
+		if !withWitness {
+			continue
+		}
+		proof := in.Proof.Encode()
+		length := make([]byte, 4)
+		binary.BigEndian.PutUint32(length, uint32(len(proof)))
+		encoded = append(encoded, length...)
+		encoded = append(encoded, proof...)
+		if len(in.Signature) > 255 {
+			return nil, fmt.Errorf("Input %v: signature is too long", i)
+		}
+		encoded = append(encoded, uint8(len(in.Signature)))
+		encoded = append(encoded, in.Signature...)
+	}
+	encoded = append(encoded, uint8(len(tx.Outputs)))
+	for i := range tx.Outputs {
+		out, err := tx.Outputs[i].Encode()
+		if err != nil {
+			return nil, fmt.Errorf("Output %v: %v", i, err)
+		}
+		encoded = append(encoded, out...)
+	}
+	return encoded, nil
+}
+
+func DecodeTransaction(data []byte) (*Transaction, error) {
+	if len(data) < 1 {
+		return nil, errors.New("Transaction is too short")
+	}
+	tx := new(Transaction)
+	numInputs := int(data[0])
+	if numInputs > MaxInputs {
+		return nil, errors.New("Too many inputs")
+	}
+	offset := 1
+	need := func(n int) error {
+		if len(data) < offset+n {
+			return errors.New("Transaction is too short")
+		}
+		return nil
+	}
+	for i := 0; i < numInputs; i++ {
+		var in Input
+		if err := need(8 + OutputLength + 4); err != nil {
+			return nil, err
+		}
+		in.Position = binary.BigEndian.Uint64(data[offset:])
+		offset += 8
+		spent, err := DecodeOutput(data[offset : offset+OutputLength])
+		if err != nil {
+			return nil, err
+		}
+		in.Spent = spent
+		offset += OutputLength
+		proofLength := int(binary.BigEndian.Uint32(data[offset:]))
+		offset += 4
+		if err := need(proofLength + 1); err != nil {
+			return nil, err
+		}
+		in.Proof, err = csmt.DecodeAuditNodes(data[offset : offset+proofLength])
+		if err != nil {
+			return nil, err
+		}
+		offset += proofLength
+		signatureLength := int(data[offset])
+		offset++
+		if err := need(signatureLength); err != nil {
+			return nil, err
+		}
+		if signatureLength != 0 {
+			in.Signature = append([]byte{}, data[offset:offset+signatureLength]...)
+		}
+		offset += signatureLength
+		tx.Inputs = append(tx.Inputs, in)
+	}
+	if err := need(1); err != nil {
+		return nil, err
+	}
+	numOutputs := int(data[offset])
+	offset++
+	if numOutputs > MaxOutputs {
+		return nil, errors.New("Too many outputs")
+	}
+	if err := need(numOutputs * OutputLength); err != nil {
+		return nil, err
+	}
+	for i := 0; i < numOutputs; i++ {
+		out, err := DecodeOutput(data[offset : offset+OutputLength])
+		if err != nil {
+			return nil, err
+		}
+		tx.Outputs = append(tx.Outputs, *out)
+		offset += OutputLength
+	}
+	if offset != len(data) {
+		return nil, errors.New("Trailing bytes after transaction")
+	}
+	return tx, nil
+}
+
+// Hash returns the hash of the canonical encoding, proofs and signatures included.
+func (tx *Transaction) Hash() ([]byte, error) {
+	encoded, err := tx.Encode()
+	if err != nil {
+		return nil, err
+	}
+	hash := sha512.Sum512_256(encoded)
+	return hash[:], nil
+}
+
+// Validate checks that the transaction is well formed, that the proof of every input
+// leads to the given root, which is the previous block's root, and that the value of every
+// token is conserved.
+//
+// Tree hashes are tagged with the side of a single child, so a proof binds the input to
+// its Position. Whoever applies transactions to the tree still checks the spent outputs
+// against the leaves, which is where the spent state lives.
+func (tx *Transaction) Validate(prevRoot []byte) error {
+	if len(tx.Inputs) == 0 {
+		return errors.New("Transaction has no inputs")
+	}
+	if len(tx.Outputs) == 0 {
+		return errors.New("Transaction has no outputs")
+	}
+	if _, err := tx.Encode(); err != nil {
+		return err
+	}
+	seen := make(map[uint64]bool, len(tx.Inputs))
+	for i := range tx.Inputs {
+		in := &tx.Inputs[i]
+		if seen[in.Position] {
+			return fmt.Errorf("Input %v spends the same output twice", i)
+		}
+		seen[in.Position] = true
+		spent, _ := in.Spent.Encode()
+		if err := in.Proof.VefiryPath(TreeHeight, in.Position, spent, prevRoot); err != nil {
+			return fmt.Errorf("Input %v: %v", i, err)
+		}
+	}
+	_, err := tx.Fees()
+	return err
+}
@@ -0,0 +1,79 @@
+package plasma
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+func testOutput(owner byte, amount int64) Output {
+	pubKey := make([]byte, PubKeyLength)
+	pubKey[0] = 0x02
+	pubKey[1] = owner
+	return Output{pubKey, make([]byte, MetadataLength), big.NewInt(amount)}
+}
+
+// testTree returns a tree with outputs of block 1 and a transaction spending the first two.
+func testTree(t *testing.T) (*csmt.CSMT, []Output, *Transaction) {
+	tree := csmt.NewCSMT(TreeHeight, true)
+	outputs := []Output{testOutput(1, 10), testOutput(2, 20), testOutput(3, 30)}
+	var toInsert csmt.InsertionIndexes
+	for i := range outputs {
+		encoded, err := outputs[i].Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		toInsert = append(toInsert, csmt.InsertedIndex{Index: csmt.UTXOIndex(1, uint64(i), 0), Value: encoded})
+	}
+	_ = tree.ApplyInserts(toInsert)
+	tx := &Transaction{
+		Inputs: []Input{
+			{Position: toInsert[0].Index, Spent: &outputs[0], Proof: tree.Prove(toInsert[0].Index)},
+			{Position: toInsert[1].Index, Spent: &outputs[1], Proof: tree.Prove(toInsert[1].Index)},
+		},
+		Outputs: []Output{testOutput(4, 25), testOutput(1, 5)},
+	}
+	return tree, outputs, tx
+}
+
+func TestTransactionEncoding(t *testing.T) {
+	_, _, tx := testTree(t)
+	tx.Inputs[0].Signature = []byte{0x01, 0x02}
+	encoded, err := tx.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeTransaction(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := decoded.Encode()
+	if bytes.Compare(encoded, again) != 0 {
+		t.Fatal("Encoding did not survive a round trip")
+	}
+	if _, err := DecodeTransaction(encoded[:len(encoded)-1]); err == nil {
+		t.Fatal("Truncated transaction was decoded")
+	}
+	if _, err := DecodeTransaction(append(encoded, 0x00)); err == nil {
+		t.Fatal("Transaction with trailing bytes was decoded")
+	}
+}
+
+func TestTransactionValidate(t *testing.T) {
+	tree, outputs, tx := testTree(t)
+	if err := tx.Validate(tree.RootHash()); err != nil {
+		t.Fatal(err)
+	}
+	stolen := testOutput(9, 10)
+	tx.Inputs[0].Spent = &stolen
+	if err := tx.Validate(tree.RootHash()); err == nil {
+		t.Fatal("Input with a wrong spent output was accepted")
+	}
+	tx.Inputs[0].Spent = &outputs[0]
+	tx.Inputs[1] = tx.Inputs[0]
+	if err := tx.Validate(tree.RootHash()); err == nil {
+		t.Fatal("Double spend within a transaction was accepted")
+	}
+}
@@ -0,0 +1,101 @@
+package plasma
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// Token identifies the asset of an output, it is the output metadata.
+type Token [MetadataLength]byte
+
+// NativeToken is the token with zero metadata.
+var NativeToken Token
+
+func tokenOf(o *Output) Token {
+	var token Token
+	copy(token[:], o.Metadata)
+	return token
+}
+
+func (t Token) String() string {
+	return "0x" + hex.EncodeToString(t[:])
+}
+
+// Fees holds the amount left to the operator for every token.
+type Fees map[Token]*big.Int
+
+// Add accumulates other into the fees, used to sum fees over a block.
+func (f Fees) Add(other Fees) {
+	for token, amount := range other {
+		if f[token] == nil {
+			f[token] = new(big.Int)
+		}
+		f[token].Add(f[token], amount)
+	}
+}
+
+var maxAmount = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), AmountLength*8), big.NewInt(1))
+
+// sumByToken sums amounts per token, a sum that does not fit into an amount is an error.
+func sumByToken(outputs []*Output) (map[Token]*big.Int, error) {
+	sums := make(map[Token]*big.Int)
+	for _, o := range outputs {
+		token := tokenOf(o)
+		if sums[token] == nil {
+			sums[token] = new(big.Int)
+		}
+		sums[token].Add(sums[token], o.Amount)
+		if sums[token].Cmp(maxAmount) > 0 {
+			return nil, fmt.Errorf("Sum of token %v overflows", token)
+		}
+	}
+	return sums, nil
+}
+
+// Fees checks that for every token the inputs cover the outputs and returns the
+// difference. Outputs must be positive. Tokens without a fee are left out.
+func (tx *Transaction) Fees() (Fees, error) {
+	spent := make([]*Output, len(tx.Inputs))
+	for i := range tx.Inputs {
+		if tx.Inputs[i].Spent == nil || tx.Inputs[i].Spent.Amount == nil {
+			return nil, fmt.Errorf("Input %v has no spent output", i)
+		}
+		spent[i] = tx.Inputs[i].Spent
+	}
+	created := make([]*Output, len(tx.Outputs))
+	for i := range tx.Outputs {
+		if tx.Outputs[i].Amount == nil || tx.Outputs[i].Amount.Sign() <= 0 {
+			return nil, fmt.Errorf("Output %v has a zero or negative amount", i)
+		}
+		created[i] = &tx.Outputs[i]
+	}
+	in, err := sumByToken(spent)
+	if err != nil {
+		return nil, err
+	}
+	out, err := sumByToken(created)
+	if err != nil {
+		return nil, err
+	}
+	fees := make(Fees)
+	for token, amount := range out {
+		available := in[token]
+		if available == nil {
+			return nil, fmt.Errorf("Token %v is not spent by any input", token)
+		}
+		if available.Cmp(amount) < 0 {
+			return nil, fmt.Errorf("Outputs of token %v spend %v, inputs only have %v", token, amount, available)
+		}
+	}
+	for token, amount := range in {
+		fee := new(big.Int).Set(amount)
+		if out[token] != nil {
+			fee.Sub(fee, out[token])
+		}
+		if fee.Sign() != 0 {
+			fees[token] = fee
+		}
+	}
+	return fees, nil
+}
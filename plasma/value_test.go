@@ -0,0 +1,58 @@
+package plasma
+
+import (
+	"math/big"
+	"testing"
+)
+
+func tokenOutput(owner byte, token byte, amount *big.Int) Output {
+	o := testOutput(owner, 0)
+	o.Metadata[0] = token
+	o.Amount = amount
+	return o
+}
+
+func TestTransactionFees(t *testing.T) {
+	spent := []Output{tokenOutput(1, 0, big.NewInt(100)), tokenOutput(1, 7, big.NewInt(50)), tokenOutput(1, 7, big.NewInt(5))}
+	tx := &Transaction{Outputs: []Output{tokenOutput(2, 0, big.NewInt(90)), tokenOutput(2, 7, big.NewInt(55))}}
+	for i := range spent {
+		tx.Inputs = append(tx.Inputs, Input{Position: uint64(i), Spent: &spent[i]})
+	}
+	fees, err := tx.Fees()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fees) != 1 || fees[NativeToken].Cmp(big.NewInt(10)) != 0 {
+		t.Fatal("Invalid fees")
+	}
+	total := make(Fees)
+	total.Add(fees)
+	total.Add(fees)
+	if total[NativeToken].Cmp(big.NewInt(20)) != 0 {
+		t.Fatal("Fees are not accumulated")
+	}
+
+	tx.Outputs[1].Amount = big.NewInt(56)
+	if _, err := tx.Fees(); err == nil {
+		t.Fatal("Token outputs above inputs were accepted")
+	}
+	tx.Outputs[1].Amount = big.NewInt(0)
+	if _, err := tx.Fees(); err == nil {
+		t.Fatal("Zero output was accepted")
+	}
+	tx.Outputs[1] = tokenOutput(2, 8, big.NewInt(1))
+	if _, err := tx.Fees(); err == nil {
+		t.Fatal("Output of a token without inputs was accepted")
+	}
+}
+
+func TestTransactionFeesOverflow(t *testing.T) {
+	spent := []Output{tokenOutput(1, 0, maxAmount), tokenOutput(1, 0, big.NewInt(1))}
+	tx := &Transaction{Outputs: []Output{tokenOutput(2, 0, big.NewInt(1))}}
+	for i := range spent {
+		tx.Inputs = append(tx.Inputs, Input{Position: uint64(i), Spent: &spent[i]})
+	}
+	if _, err := tx.Fees(); err == nil {
+		t.Fatal("Overflowing inputs were accepted")
+	}
+}
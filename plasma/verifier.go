@@ -0,0 +1,204 @@
+package plasma
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+// FailureReason tells why a block was rejected.
+type FailureReason int
+
+const (
+	InvalidHeader        FailureReason = iota + 1 // number or previous hash do not follow the previous header
+	InvalidTxRoot                                 // tx root does not match the transactions
+	MalformedTransaction                          // transaction can not be encoded or has no inputs or outputs
+	DoubleSpend                                   // an output is spent twice within the block
+	InvalidInputProof                             // input is not in the previous block's tree
+	InvalidSignature                              // input is not signed by the owner
+	ValueNotConserved                             // outputs of a token are worth more than inputs, or an output is zero
+	InvalidAuditData                              // audit data is malformed or does not match the header
+	InvalidSMTRoot                                // new root does not follow from the block
+	InvalidDeposit                                // deposit block does not mint the next parent chain deposits
+)
+
+var failureReasons = map[FailureReason]string{
+	InvalidHeader:        "invalid header",
+	InvalidTxRoot:        "invalid tx root",
+	MalformedTransaction: "malformed transaction",
+	DoubleSpend:          "double spend",
+	InvalidInputProof:    "invalid input proof",
+	InvalidSignature:     "invalid signature",
+	ValueNotConserved:    "value not conserved",
+	InvalidAuditData:     "invalid audit data",
+	InvalidSMTRoot:       "invalid SMT root",
+	InvalidDeposit:       "invalid deposit",
+}
+
+func (r FailureReason) String() string {
+	if s, exists := failureReasons[r]; exists {
+		return s
+	}
+	return fmt.Sprintf("unknown reason %d", int(r))
+}
+
+// VerificationError is returned by VerifyBlock. TxIndex and InputIndex point to the
+// offending transaction and input, they are -1 when the failure is not about one.
+type VerificationError struct {
+	Reason     FailureReason
+	TxIndex    int
+	InputIndex int
+	Err        error
+}
+
+func (e *VerificationError) Error() string {
+	switch {
+	case e.InputIndex >= 0:
+		return fmt.Sprintf("%v in transaction %v input %v: %v", e.Reason, e.TxIndex, e.InputIndex, e.Err)
+	case e.TxIndex >= 0:
+		return fmt.Sprintf("%v in transaction %v: %v", e.Reason, e.TxIndex, e.Err)
+	}
+	return fmt.Sprintf("%v: %v", e.Reason, e.Err)
+}
+
+func blockFailure(reason FailureReason, err error) *VerificationError {
+	return &VerificationError{reason, -1, -1, err}
+}
+
+func txFailure(reason FailureReason, tx int, err error) *VerificationError {
+	return &VerificationError{reason, tx, -1, err}
+}
+
+func inputFailure(reason FailureReason, tx, input int, err error) *VerificationError {
+	return &VerificationError{reason, tx, input, err}
+}
+
+// VerifyBlock checks a block using only the previous header, nil for the first block, and
+// the audit data published with the block:
+//
+//	every input is in the previous block's tree and is signed by its owner
+//	the new tree root follows from the spent inputs, the new outputs and the audit data
+//	the header commits to the transactions and to the audit data
+//	the header does not claim to mint deposits, deposit blocks go through VerifyDepositBlock
+//
+// Any failure is returned as a *VerificationError.
+func VerifyBlock(prev *BlockHeader, block *Block, auditData []byte) error {
+	header := &block.Header
+	prevRoot, err := verifyHeaderLink(prev, block)
+	if err != nil {
+		return err
+	}
+	if header.Deposits != 0 || header.DepositNonce != 0 {
+		return blockFailure(InvalidDeposit, errors.New("Deposit block has to be verified against the deposits"))
+	}
+	for i, tx := range block.Transactions {
+		if len(tx.Inputs) == 0 || len(tx.Outputs) == 0 {
+			return txFailure(MalformedTransaction, i, errors.New("Transaction has no inputs or outputs"))
+		}
+		if _, err := tx.Encode(); err != nil {
+			return txFailure(MalformedTransaction, i, err)
+		}
+	}
+	if err := block.CheckTransactionRoot(); err != nil {
+		return blockFailure(InvalidTxRoot, err)
+	}
+
+	oldLeaves := make(map[uint64][]byte)
+	newLeaves := make(map[uint64][]byte)
+	for i, tx := range block.Transactions {
+		hash, _ := tx.SigningHash()
+		for j := range tx.Inputs {
+			in := &tx.Inputs[j]
+			if _, spent := oldLeaves[in.Position]; spent {
+				return inputFailure(DoubleSpend, i, j, errors.New("Output is already spent in this block"))
+			}
+			value, _ := in.Spent.Encode()
+			if err := in.Proof.VefiryPath(TreeHeight, in.Position, value, prevRoot); err != nil {
+				return inputFailure(InvalidInputProof, i, j, err)
+			}
+			if err := VerifySignature(in.Spent.PubKey, hash, in.Signature); err != nil {
+				return inputFailure(InvalidSignature, i, j, err)
+			}
+			oldLeaves[in.Position], newLeaves[in.Position] = csmt.LeafHash(value), nil
+		}
+		if _, err := tx.Fees(); err != nil {
+			return txFailure(ValueNotConserved, i, err)
+		}
+		for j := range tx.Outputs {
+			value, _ := tx.Outputs[j].Encode()
+			index := csmt.UTXOIndex(header.Number, uint64(i), uint64(j))
+			oldLeaves[index], newLeaves[index] = nil, csmt.LeafHash(value)
+		}
+	}
+	audit, err := decodeAuditData(header, auditData)
+	if err != nil {
+		return err
+	}
+	return verifyTransition(prevRoot, header, audit, oldLeaves, newLeaves)
+}
+
+// verifyHeaderLink checks that the block follows the previous header, nil for the first
+// block, and returns the previous root.
+func verifyHeaderLink(prev *BlockHeader, block *Block) ([]byte, error) {
+	if len(block.Transactions) > MaxTransactions {
+		return nil, blockFailure(MalformedTransaction, errors.New("Too many transactions"))
+	}
+	if prev == nil {
+		if block.Header.Number != 1 || len(block.Header.PrevHash) != 0 {
+			return nil, blockFailure(InvalidHeader, errors.New("Block is not the first one"))
+		}
+		return nil, nil
+	}
+	prevHash, err := prev.Hash()
+	if err != nil {
+		return nil, blockFailure(InvalidHeader, err)
+	}
+	if block.Header.Number != prev.Number+1 || block.Header.Number > MaxBlockNumber {
+		return nil, blockFailure(InvalidHeader, errors.New("Block number does not follow the previous header"))
+	}
+	if bytes.Compare(block.Header.PrevHash, prevHash) != 0 {
+		return nil, blockFailure(InvalidHeader, errors.New("Previous hash does not match"))
+	}
+	return prev.SMTRoot, nil
+}
+
+// decodeAuditData decodes the audit data and checks it against the header commitment.
+func decodeAuditData(header *BlockHeader, auditData []byte) (csmt.AuditNodes, error) {
+	audit, err := csmt.DecodeAuditNodes(auditData)
+	if err != nil {
+		return nil, blockFailure(InvalidAuditData, err)
+	}
+	commitment, err := audit.Commitment()
+	if err != nil {
+		return nil, blockFailure(InvalidAuditData, err)
+	}
+	if bytes.Compare(commitment, header.AuditCommitment) != 0 {
+		return nil, blockFailure(InvalidAuditData, errors.New("Audit data does not match the header commitment"))
+	}
+	return audit, nil
+}
+
+// verifyTransition checks that the touched leaves lead from the previous root to the new
+// one through the decoded audit data.
+func verifyTransition(prevRoot []byte, header *BlockHeader, audit csmt.AuditNodes, oldLeaves, newLeaves map[uint64][]byte) error {
+	if len(newLeaves) == 0 {
+		if len(audit) != 0 || bytes.Compare(header.SMTRoot, prevRoot) != 0 {
+			return blockFailure(InvalidSMTRoot, errors.New("Empty block changes the tree"))
+		}
+		return nil
+	}
+	oldRoot, newRoot, err := audit.TransitionRoots(csmt.SHA512_256, TreeHeight, oldLeaves, newLeaves)
+	if err != nil {
+		return blockFailure(InvalidAuditData, err)
+	}
+	// new outputs are checked to be empty before the block here as well
+	if bytes.Compare(oldRoot, prevRoot) != 0 {
+		return blockFailure(InvalidAuditData, errors.New("Audit data does not lead to the previous root"))
+	}
+	if bytes.Compare(newRoot, header.SMTRoot) != 0 {
+		return blockFailure(InvalidSMTRoot, errors.New("Block does not lead to the claimed root"))
+	}
+	return nil
+}
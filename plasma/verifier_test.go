@@ -0,0 +1,122 @@
+package plasma
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"testing"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+)
+
+func expectFailure(t *testing.T, err error, reason FailureReason, tx int) {
+	failure, ok := err.(*VerificationError)
+	if !ok {
+		t.Fatalf("Expected %v, got %v", reason, err)
+	}
+	if failure.Reason != reason || failure.TxIndex != tx {
+		t.Fatalf("Expected %v in transaction %v, got %v", reason, tx, failure)
+	}
+}
+
+func TestVerifyBlock(t *testing.T) {
+	producer, prev, tx := testProducer(t)
+	produced, err := producer.Produce(prev, []*Transaction{tx}, 1541116800)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, audit := produced.Block, produced.AuditData
+	if err := VerifyBlock(prev, block, audit); err != nil {
+		t.Fatal(err)
+	}
+
+	block.Header.SMTRoot[0] ^= 0xff
+	expectFailure(t, VerifyBlock(prev, block, audit), InvalidSMTRoot, -1)
+	block.Header.SMTRoot[0] ^= 0xff
+
+	audit[len(audit)-1] ^= 0xff
+	expectFailure(t, VerifyBlock(prev, block, audit), InvalidAuditData, -1)
+	audit[len(audit)-1] ^= 0xff
+
+	block.Header.Number = 3
+	expectFailure(t, VerifyBlock(prev, block, audit), InvalidHeader, -1)
+	block.Header.Number = 2
+
+	block.Transactions = append(block.Transactions, tx)
+	expectFailure(t, VerifyBlock(prev, block, audit), InvalidTxRoot, -1)
+	block.Header.TxRoot, _ = block.TransactionRoot()
+	expectFailure(t, VerifyBlock(prev, block, audit), DoubleSpend, 1)
+}
+
+func TestVerifyBlockRejectsInvalidTransactions(t *testing.T) {
+	producer, prev, tx := testProducer(t)
+	produced, err := producer.Produce(prev, []*Transaction{tx}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := produced.Block
+	verify := func() error {
+		block.Header.TxRoot, _ = block.TransactionRoot()
+		return VerifyBlock(prev, block, produced.AuditData)
+	}
+
+	tx.Inputs[1].Signature[0] ^= 0xff
+	expectFailure(t, verify(), InvalidSignature, 0)
+	tx.Inputs[1].Signature[0] ^= 0xff
+
+	tx.Inputs[0].Proof[0].Value = bytes.Repeat([]byte{0x01}, 32)
+	expectFailure(t, verify(), InvalidInputProof, 0)
+
+	_, prev, tx = testProducer(t)
+	tx.Outputs[0].Amount = big.NewInt(1000)
+	ed, secp := testSigners(t)
+	_ = tx.SignInput(0, ed)
+	_ = tx.SignInput(1, secp)
+	block = &Block{Header: BlockHeader{Number: 2}, Transactions: []*Transaction{tx}}
+	block.Header.PrevHash, _ = prev.Hash()
+	expectFailure(t, verify(), ValueNotConserved, 0)
+}
+
+func TestVerifyEmptyBlock(t *testing.T) {
+	producer, prev, _ := testProducer(t)
+	produced, err := producer.Produce(prev, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBlock(prev, produced.Block, produced.AuditData); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyBlockRejectsRelabelledInput(t *testing.T) {
+	producer, prev, tx := testProducer(t)
+	relabel(&tx.Inputs[0])
+	ed, secp := testSigners(t)
+	_ = tx.SignInput(0, ed)
+	_ = tx.SignInput(1, secp)
+	// the operator skips its own checks and signs the block
+	header, err := producer.nextHeader(prev, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spent, created csmt.InsertionIndexes
+	for i := range tx.Inputs {
+		value, _ := tx.Inputs[i].Spent.Encode()
+		spent = append(spent, csmt.InsertedIndex{Index: tx.Inputs[i].Position, Value: value})
+	}
+	sort.Sort(spent)
+	for i := range tx.Outputs {
+		value, _ := tx.Outputs[i].Encode()
+		created = append(created, csmt.InsertedIndex{Index: csmt.UTXOIndex(2, 0, uint64(i)), Value: value})
+	}
+	produced, err := producer.apply(&Block{Header: header, Transactions: []*Transaction{tx}}, spent, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// node hashes commit to the side of a single child, so the moved proof fails
+	err = VerifyBlock(prev, produced.Block, produced.AuditData)
+	expectFailure(t, err, InvalidInputProof, 0)
+	if err.(*VerificationError).InputIndex != 0 {
+		t.Fatal("Failure points to another input")
+	}
+}
@@ -0,0 +1,356 @@
+package plasma
+
+import (
+	"errors"
+	"fmt"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+	"github.com/matterinc/PlasmaCompact/rlp"
+)
+
+// RLP wire format, integers are canonical RLP integers:
+//
+//	output: [pubkey, metadata, amount]
+//	audit node: [level, index, value, left sibling, right sibling]
+//	proof and audit set: [node, ...]
+//	input: [position, spent output, proof, signature]
+//	transaction: [[input, ...], [output, ...]]
+//	header: [number, prev hash, tx root, SMT root, audit commitment, timestamp, deposits, deposit nonce, signature]
+//	block: [header, [transaction, ...]]
+
+func encodeOutputRLP(o *Output) ([]byte, error) {
+	if _, err := o.Encode(); err != nil {
+		return nil, err
+	}
+	return rlp.EncodeList(rlp.EncodeBytes(o.PubKey), rlp.EncodeBytes(o.Metadata), rlp.EncodeBigInt(o.Amount)), nil
+}
+
+func decodeOutputRLP(item rlp.Item) (*Output, error) {
+	fields, err := item.ListOf(3)
+	if err != nil {
+		return nil, err
+	}
+	o := &Output{PubKey: fields[0].Bytes, Metadata: fields[1].Bytes}
+	if fields[0].List || fields[1].List {
+		return nil, errors.New("Expected an RLP string")
+	}
+	if o.Amount, err = fields[2].BigInt(AmountLength); err != nil {
+		return nil, err
+	}
+	if _, err := o.Encode(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func encodeAuditNodeRLP(n csmt.AuditNode) []byte {
+	return rlp.EncodeList(rlp.EncodeUint(uint64(n.Level)), rlp.EncodeUint(n.Index),
+		rlp.EncodeBytes(n.Value), rlp.EncodeBytes(n.LeftSibling), rlp.EncodeBytes(n.RightSibling))
+}
+
+// EncodeAuditNodesRLP encodes a proof or a per-block audit set.
+func EncodeAuditNodesRLP(d csmt.AuditNodes) []byte {
+	nodes := make([][]byte, len(d))
+	for i := range d {
+		nodes[i] = encodeAuditNodeRLP(d[i])
+	}
+	return rlp.EncodeList(nodes...)
+}
+
+func decodeAuditNodesRLP(item rlp.Item) (csmt.AuditNodes, error) {
+	items, err := item.ListOf(-1)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(csmt.AuditNodes, len(items))
+	for i := range items {
+		fields, err := items[i].ListOf(5)
+		if err != nil {
+			return nil, err
+		}
+		level, err := fields[0].Uint()
+		if err != nil {
+			return nil, err
+		}
+		if level > 255 {
+			return nil, errors.New("Audit node level is out of range")
+		}
+		nodes[i].Level = uint8(level)
+		if nodes[i].Index, err = fields[1].Uint(); err != nil {
+			return nil, err
+		}
+		hashes := make([][]byte, 3)
+		for j := range hashes {
+			if hashes[j], err = fields[2+j].String(); err != nil {
+				return nil, err
+			}
+			if len(hashes[j]) > 255 {
+				return nil, errors.New("Audit node hash is too long")
+			}
+		}
+		nodes[i].Value, nodes[i].LeftSibling, nodes[i].RightSibling = hashes[0], hashes[1], hashes[2]
+	}
+	return nodes, nil
+}
+
+// DecodeAuditNodesRLP decodes a proof or a per-block audit set.
+func DecodeAuditNodesRLP(data []byte) (csmt.AuditNodes, error) {
+	item, err := rlp.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAuditNodesRLP(item)
+}
+
+// EncodeRLP returns the RLP encoding of the transaction.
+func (tx *Transaction) EncodeRLP() ([]byte, error) {
+	if len(tx.Inputs) > MaxInputs || len(tx.Outputs) > MaxOutputs {
+		return nil, errors.New("Too many inputs or outputs")
+	}
+	inputs := make([][]byte, len(tx.Inputs))
+	for i := range tx.Inputs {
+		in := &tx.Inputs[i]
+		if in.Spent == nil {
+			return nil, fmt.Errorf("Input %v has no spent output", i)
+		}
+		spent, err := encodeOutputRLP(in.Spent)
+		if err != nil {
+			return nil, fmt.Errorf("Input %v: %v", i, err)
+		}
+		inputs[i] = rlp.EncodeList(rlp.EncodeUint(in.Position), spent, EncodeAuditNodesRLP(in.Proof), rlp.EncodeBytes(in.Signature))
+	}
+	outputs := make([][]byte, len(tx.Outputs))
+	for i := range tx.Outputs {
+		out, err := encodeOutputRLP(&tx.Outputs[i])
+		if err != nil {
+			return nil, fmt.Errorf("Output %v: %v", i, err)
+		}
+		outputs[i] = out
+	}
+	return rlp.EncodeList(rlp.EncodeList(inputs...), rlp.EncodeList(outputs...)), nil
+}
+
+func decodeTransactionRLP(item rlp.Item) (*Transaction, error) {
+	parts, err := item.ListOf(2)
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := parts[0].ListOf(-1)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := parts[1].ListOf(-1)
+	if err != nil {
+		return nil, err
+	}
+	if len(inputs) > MaxInputs || len(outputs) > MaxOutputs {
+		return nil, errors.New("Too many inputs or outputs")
+	}
+	tx := new(Transaction)
+	for i := range inputs {
+		fields, err := inputs[i].ListOf(4)
+		if err != nil {
+			return nil, err
+		}
+		var in Input
+		if in.Position, err = fields[0].Uint(); err != nil {
+			return nil, err
+		}
+		if in.Spent, err = decodeOutputRLP(fields[1]); err != nil {
+			return nil, err
+		}
+		if in.Proof, err = decodeAuditNodesRLP(fields[2]); err != nil {
+			return nil, err
+		}
+		if in.Signature, err = fields[3].String(); err != nil {
+			return nil, err
+		}
+		tx.Inputs = append(tx.Inputs, in)
+	}
+	for i := range outputs {
+		out, err := decodeOutputRLP(outputs[i])
+		if err != nil {
+			return nil, err
+		}
+		tx.Outputs = append(tx.Outputs, *out)
+	}
+	return tx, nil
+}
+
+// DecodeTransactionRLP decodes a transaction, checking the same limits as Encode.
+func DecodeTransactionRLP(data []byte) (*Transaction, error) {
+	item, err := rlp.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTransactionRLP(item)
+}
+
+// EncodeRLP returns the RLP encoding of the header.
+func (h *BlockHeader) EncodeRLP() []byte {
+	return rlp.EncodeList(rlp.EncodeUint(h.Number), rlp.EncodeBytes(h.PrevHash), rlp.EncodeBytes(h.TxRoot),
+		rlp.EncodeBytes(h.SMTRoot), rlp.EncodeBytes(h.AuditCommitment), rlp.EncodeUint(h.Timestamp), rlp.EncodeUint(h.Deposits), rlp.EncodeUint(h.DepositNonce),
+		rlp.EncodeBytes(h.Signature))
+}
+
+func decodeBlockHeaderRLP(item rlp.Item) (*BlockHeader, error) {
+	fields, err := item.ListOf(9)
+	if err != nil {
+		return nil, err
+	}
+	h := new(BlockHeader)
+	if h.Number, err = fields[0].Uint(); err != nil {
+		return nil, err
+	}
+	if h.Timestamp, err = fields[5].Uint(); err != nil {
+		return nil, err
+	}
+	if h.Deposits, err = fields[6].Uint(); err != nil {
+		return nil, err
+	}
+	if h.DepositNonce, err = fields[7].Uint(); err != nil {
+		return nil, err
+	}
+	hashes := []*[]byte{&h.PrevHash, &h.TxRoot, &h.SMTRoot, &h.AuditCommitment}
+	for i, hash := range hashes {
+		if *hash, err = fields[1+i].String(); err != nil {
+			return nil, err
+		}
+	}
+	if h.Signature, err = fields[8].String(); err != nil {
+		return nil, err
+	}
+	// the binary encoding limits every field to 255 bytes, keep both formats equivalent
+	if _, err := h.Encode(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// DecodeBlockHeaderRLP decodes a header including its signature.
+func DecodeBlockHeaderRLP(data []byte) (*BlockHeader, error) {
+	item, err := rlp.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBlockHeaderRLP(item)
+}
+
+// EncodeRLP returns the RLP encoding of the block.
+func (b *Block) EncodeRLP() ([]byte, error) {
+	if len(b.Transactions) > MaxTransactions {
+		return nil, errors.New("Too many transactions")
+	}
+	txs := make([][]byte, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		encoded, err := tx.EncodeRLP()
+		if err != nil {
+			return nil, fmt.Errorf("Transaction %v: %v", i, err)
+		}
+		txs[i] = encoded
+	}
+	return rlp.EncodeList(b.Header.EncodeRLP(), rlp.EncodeList(txs...)), nil
+}
+
+// DecodeBlockRLP decodes a block with all its transactions.
+func DecodeBlockRLP(data []byte) (*Block, error) {
+	item, err := rlp.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := item.ListOf(2)
+	if err != nil {
+		return nil, err
+	}
+	header, err := decodeBlockHeaderRLP(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	txs, err := parts[1].ListOf(-1)
+	if err != nil {
+		return nil, err
+	}
+	if len(txs) > MaxTransactions {
+		return nil, errors.New("Too many transactions")
+	}
+	b := &Block{Header: *header, Transactions: make([]*Transaction, len(txs))}
+	for i := range txs {
+		if b.Transactions[i], err = decodeTransactionRLP(txs[i]); err != nil {
+			return nil, fmt.Errorf("Transaction %v: %v", i, err)
+		}
+	}
+	return b, nil
+}
+
+// WireSizes breaks down the RLP size of a block and its audit data. Proofs are the price
+// paid in bandwidth for verifiers not having to store the set of unspent outputs.
+type WireSizes struct {
+	Block        int `json:"block"`
+	Header       int `json:"header"`
+	Transactions int `json:"transactions"`
+	Proofs       int `json:"proofs"`     // input proofs included into the transactions
+	Signatures   int `json:"signatures"` // input signatures included into the transactions
+	AuditData    int `json:"audit_data"`
+	// audit data in the compressed stream, without what the block itself tells
+	CompressedAuditData int `json:"compressed_audit_data"`
+}
+
+// WireSizes measures the block together with the audit data published next to it.
+func (b *Block) WireSizes(audit csmt.AuditNodes) (*WireSizes, error) {
+	encoded, err := b.EncodeRLP()
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := b.CompressAudit(audit)
+	if err != nil {
+		return nil, err
+	}
+	sizes := &WireSizes{Block: len(encoded), Header: len(b.Header.EncodeRLP()), AuditData: len(EncodeAuditNodesRLP(audit)), CompressedAuditData: len(compressed)}
+	for _, tx := range b.Transactions {
+		encodedTx, _ := tx.EncodeRLP()
+		sizes.Transactions += len(encodedTx)
+		for i := range tx.Inputs {
+			sizes.Proofs += len(EncodeAuditNodesRLP(tx.Inputs[i].Proof))
+			sizes.Signatures += len(rlp.EncodeBytes(tx.Inputs[i].Signature))
+		}
+	}
+	return sizes, nil
+}
+
+// touchedLeaves returns the leaf hashes after the block for every index it touches.
+func (b *Block) touchedLeaves() (map[uint64][]byte, error) {
+	leaves := make(map[uint64][]byte)
+	for i, tx := range b.Transactions {
+		for j := range tx.Inputs {
+			leaves[tx.Inputs[j].Position] = nil
+		}
+		for j := range tx.Outputs {
+			value, err := tx.Outputs[j].Encode()
+			if err != nil {
+				return nil, err
+			}
+			leaves[csmt.UTXOIndex(b.Header.Number, uint64(i), uint64(j))] = csmt.LeafHash(value)
+		}
+	}
+	return leaves, nil
+}
+
+// CompressAudit compresses the audit data of the block for clients that download the block
+// anyway: spent and created leaves are rebuilt from the block and left out.
+func (b *Block) CompressAudit(audit csmt.AuditNodes) ([]byte, error) {
+	leaves, err := b.touchedLeaves()
+	if err != nil {
+		return nil, err
+	}
+	return audit.CompressAudit(csmt.SHA512_256, TreeHeight, leaves)
+}
+
+// DecompressAudit rebuilds the audit data of the block in the canonical order. The result
+// still has to be checked against the audit commitment in the header.
+func (b *Block) DecompressAudit(data []byte) (csmt.AuditNodes, error) {
+	leaves, err := b.touchedLeaves()
+	if err != nil {
+		return nil, err
+	}
+	return csmt.DecompressAudit(csmt.SHA512_256, TreeHeight, data, leaves)
+}
@@ -0,0 +1,119 @@
+package plasma
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/matterinc/PlasmaCompact/rlp"
+)
+
+func TestBlockRLPRoundTrip(t *testing.T) {
+	c := newTestChain(t)
+	produced, err := c.producer.Produce(c.last(), []*Transaction{c.spend(t, 60, 40), c.spendSecp(t, 50)}, c.clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := produced.Block.EncodeRLP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeBlockRLP(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reencoded, _ := decoded.EncodeRLP()
+	if bytes.Compare(encoded, reencoded) != 0 {
+		t.Fatal("Block changed after a round trip")
+	}
+	first, _ := produced.Block.Encode()
+	second, _ := decoded.Encode()
+	if bytes.Compare(first, second) != 0 {
+		t.Fatal("Binary encoding changed after a round trip")
+	}
+	if err := decoded.Header.VerifySignature(c.producer.signer.PubKey()); err != nil {
+		t.Fatal(err)
+	}
+
+	audit, err := DecodeAuditNodesRLP(EncodeAuditNodesRLP(produced.Audit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitment, _ := audit.Commitment()
+	if bytes.Compare(commitment, decoded.Header.AuditCommitment) != 0 {
+		t.Fatal("Audit set changed after a round trip")
+	}
+
+	compressed, err := decoded.CompressAudit(produced.Audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit, err = decoded.DecompressAudit(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitment, _ = audit.Commitment()
+	if bytes.Compare(commitment, decoded.Header.AuditCommitment) != 0 {
+		t.Fatal("Audit set changed after compression")
+	}
+
+	sizes, err := produced.Block.WireSizes(produced.Audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sizes.Proofs == 0 || sizes.CompressedAuditData != len(compressed) || sizes.Header+sizes.Transactions > sizes.Block || sizes.Proofs+sizes.Signatures > sizes.Transactions {
+		t.Fatal("Invalid size breakdown")
+	}
+	t.Logf("block %v bytes: header %v, transactions %v (proofs %v, signatures %v), audit data %v (%v compressed)",
+		sizes.Block, sizes.Header, sizes.Transactions, sizes.Proofs, sizes.Signatures, sizes.AuditData, sizes.CompressedAuditData)
+}
+
+func TestRLPRejectsMalformed(t *testing.T) {
+	c := newTestChain(t)
+	tx := c.spend(t, 60, 40)
+	encoded, err := tx.EncodeRLP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeTransactionRLP(encoded[:len(encoded)-1]); err == nil {
+		t.Fatal("Truncated transaction was decoded")
+	}
+	if _, err := DecodeTransactionRLP(append(encoded, 0x00)); err == nil {
+		t.Fatal("Transaction with trailing bytes was decoded")
+	}
+
+	output := func(pubkey []byte, amount []byte) []byte {
+		return rlp.EncodeList(rlp.EncodeBytes(pubkey), rlp.EncodeBytes(make([]byte, MetadataLength)), rlp.EncodeBytes(amount))
+	}
+	pubkey := tx.Outputs[0].PubKey
+	for _, out := range [][]byte{
+		output(pubkey[1:], []byte{0x01}),               // short public key
+		output(pubkey, bytes.Repeat([]byte{0x01}, 33)), // amount above 256 bits
+		output(pubkey, []byte{0x00, 0x01}),             // leading zero in the amount
+		rlp.EncodeList(rlp.EncodeBytes(pubkey)),        // missing fields
+	} {
+		if _, err := DecodeTransactionRLP(rlp.EncodeList(rlp.EncodeList(), rlp.EncodeList(out))); err == nil {
+			t.Fatal("Malformed output was decoded")
+		}
+	}
+	outputs := make([][]byte, MaxOutputs+1)
+	for i := range outputs {
+		outputs[i] = output(pubkey, big.NewInt(1).Bytes())
+	}
+	if _, err := DecodeTransactionRLP(rlp.EncodeList(rlp.EncodeList(), rlp.EncodeList(outputs...))); err == nil {
+		t.Fatal("Transaction with too many outputs was decoded")
+	}
+
+	header := c.last().EncodeRLP()
+	if _, err := DecodeBlockHeaderRLP(header[:len(header)-1]); err == nil {
+		t.Fatal("Truncated header was decoded")
+	}
+	hash := rlp.EncodeBytes(make([]byte, 256))
+	if _, err := DecodeBlockHeaderRLP(rlp.EncodeList(rlp.EncodeUint(1), hash, hash, hash, hash, rlp.EncodeUint(0), rlp.EncodeBytes(nil))); err == nil {
+		t.Fatal("Header with a too long hash was decoded")
+	}
+	node := rlp.EncodeList(rlp.EncodeUint(256), rlp.EncodeUint(0), rlp.EncodeBytes(nil), rlp.EncodeBytes(nil), rlp.EncodeBytes(nil))
+	if _, err := DecodeAuditNodesRLP(rlp.EncodeList(node)); err == nil {
+		t.Fatal("Audit node with a level above 255 was decoded")
+	}
+}
@@ -0,0 +1,285 @@
+// Package proofservice follows the chain, keeps the tree of unspent outputs and serves fresh
+// proofs over HTTP, so light clients can outsource proof updates:
+//
+//	GET  /proof/{index}?root=0x..   proof of the leaf against the latest root
+//	POST /proof/update              body {"index": n, "proof": [...]}, an old proof to update
+//
+// Both return {"index", "block", "root", "value", "proof"}. The root parameter is optional,
+// if it is a known but older root the answer is 409 so the client fetches the new headers.
+package proofservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+	"github.com/matterinc/PlasmaCompact/plasma"
+)
+
+// followedBlock is a processed block: its root and the audit set leading to it.
+type followedBlock struct {
+	root  []byte
+	audit csmt.AuditNodes
+}
+
+// Service keeps its own copy of the tree in sync with the chain.
+type Service struct {
+	mu      sync.RWMutex
+	tree    *csmt.CSMT
+	last    *plasma.BlockHeader
+	history int
+	blocks  []followedBlock // oldest first
+}
+
+// New creates a service for a new chain. Old proofs can be updated if they are against
+// one of the last history roots.
+func New(history int) *Service {
+	return &Service{tree: csmt.NewCSMT(plasma.TreeHeight, true), history: history}
+}
+
+// ApplyBlock applies the next block to the tree. The block must follow the last one and
+// lead to the root in its header, otherwise nothing is changed.
+func (s *Service) ApplyBlock(block *plasma.Block, auditData []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	header := &block.Header
+	if s.last == nil {
+		if header.Number != 1 || len(header.PrevHash) != 0 {
+			return errors.New("Block is not the first one")
+		}
+	} else {
+		prevHash, err := s.last.Hash()
+		if err != nil {
+			return err
+		}
+		if header.Number != s.last.Number+1 || bytes.Compare(header.PrevHash, prevHash) != 0 {
+			return errors.New("Block does not follow the last one")
+		}
+	}
+	audit, err := csmt.DecodeAuditNodes(auditData)
+	if err != nil {
+		return err
+	}
+	if commitment, err := audit.Commitment(); err != nil || bytes.Compare(commitment, header.AuditCommitment) != 0 {
+		return errors.New("Audit data does not match the header commitment")
+	}
+	var spent, created csmt.InsertionIndexes
+	for i, tx := range block.Transactions {
+		// checks every input and output before any of them is used
+		if _, err := tx.Encode(); err != nil {
+			return fmt.Errorf("Transaction %v: %v", i, err)
+		}
+		for j := range tx.Inputs {
+			value, err := tx.Inputs[j].Spent.Encode()
+			if err != nil {
+				return err
+			}
+			spent = append(spent, csmt.InsertedIndex{Index: tx.Inputs[j].Position, Value: value})
+		}
+		for j := range tx.Outputs {
+			value, err := tx.Outputs[j].Encode()
+			if err != nil {
+				return err
+			}
+			created = append(created, csmt.InsertedIndex{Index: csmt.UTXOIndex(header.Number, uint64(i), uint64(j)), Value: value})
+		}
+	}
+	sort.Sort(spent)
+	deletions := make(csmt.DeletionIndexes, len(spent))
+	for i := range spent {
+		if i != 0 && spent[i].Index == spent[i-1].Index {
+			return errors.New("Block spends an output twice")
+		}
+		if bytes.Compare(s.tree.Leaf(spent[i].Index), spent[i].Value) != 0 {
+			return errors.New("Block spends an output that is not in the tree")
+		}
+		deletions[i] = spent[i].Index
+	}
+	s.tree.ApplyDeletes(deletions)
+	s.tree.ApplyInserts(created)
+	if bytes.Compare(s.tree.RootHash(), header.SMTRoot) != 0 {
+		undo := make(csmt.DeletionIndexes, len(created))
+		for i := range created {
+			undo[i] = created[i].Index
+		}
+		s.tree.ApplyDeletes(undo)
+		s.tree.ApplyInserts(spent)
+		return errors.New("Block does not lead to the root in its header")
+	}
+	s.last = header
+	s.blocks = append(s.blocks, followedBlock{header.SMTRoot, audit})
+	if len(s.blocks) > s.history+1 {
+		s.blocks = s.blocks[len(s.blocks)-s.history-1:]
+	}
+	return nil
+}
+
+// ProofResponse is the answer to both proof requests.
+type ProofResponse struct {
+	Index uint64          `json:"index"`
+	Block uint64          `json:"block"`
+	Root  string          `json:"root"`
+	Value string          `json:"value"` // leaf value, empty for an empty leaf
+	Proof csmt.AuditNodes `json:"proof"`
+}
+
+// UpdateRequest is the body of a proof update request.
+type UpdateRequest struct {
+	Index uint64          `json:"index"`
+	Proof csmt.AuditNodes `json:"proof"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Service) response(index uint64, proof csmt.AuditNodes) *ProofResponse {
+	return &ProofResponse{index, s.last.Number, csmt.EncodeHex(s.last.SMTRoot), csmt.EncodeHex(s.tree.Leaf(index)), proof}
+}
+
+// blockAge returns how many blocks ago the root was the latest one, -1 if it is unknown.
+func (s *Service) blockAge(root []byte) int {
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		if bytes.Compare(s.blocks[i].root, root) == 0 {
+			return len(s.blocks) - 1 - i
+		}
+	}
+	return -1
+}
+
+var (
+	errUnknownRoot = errors.New("Unknown root")
+	errStaleRoot   = errors.New("Root is not the latest one")
+)
+
+// Proof returns the proof of the leaf against the latest root.
+func (s *Service) Proof(index uint64) (*ProofResponse, error) {
+	return s.proof(index, nil)
+}
+
+// proof checks that the root, unless nil, is the latest one and builds the proof under the
+// same lock, so a block applied in between can not move the proof to another root.
+func (s *Service) proof(index uint64, root []byte) (*ProofResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if root != nil {
+		switch age := s.blockAge(root); {
+		case age < 0:
+			return nil, errUnknownRoot
+		case age > 0:
+			return nil, errStaleRoot
+		}
+	}
+	if s.last == nil {
+		return nil, errors.New("No blocks yet")
+	}
+	return s.response(index, s.tree.Prove(index)), nil
+}
+
+// Update brings an old proof to the latest root by replaying the audit sets of the blocks
+// after its root. The proof has to be valid against that root.
+func (s *Service) Update(index uint64, proof csmt.AuditNodes) (*ProofResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.last == nil {
+		return nil, errors.New("No blocks yet")
+	}
+	if len(proof) == 0 {
+		return nil, errors.New("Proof can not be empty")
+	}
+	age := s.blockAge(proof[0].Value)
+	if age < 0 {
+		return nil, errors.New("Proof is against an unknown root")
+	}
+	value := s.tree.Leaf(index)
+	if value == nil {
+		return nil, errors.New("Output is spent")
+	}
+	if err := proof.VefiryPath(plasma.TreeHeight, index, value, proof[0].Value); err != nil {
+		return nil, err
+	}
+	updated := proof
+	var err error
+	for _, b := range s.blocks[len(s.blocks)-age:] {
+		if updated, err = updated.UpdateProofImproved(index, b.audit); err != nil {
+			return nil, err
+		}
+	}
+	if err := updated.VefiryPath(plasma.TreeHeight, index, value, s.last.SMTRoot); err != nil {
+		return nil, err
+	}
+	return s.response(index, updated), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{err.Error()})
+}
+
+// Handler returns the HTTP API of the service.
+func (s *Service) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/proof/update", s.handleUpdate)
+	mux.HandleFunc("/proof/", s.handleProof)
+	return mux
+}
+
+func (s *Service) handleProof(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("Method is not allowed"))
+		return
+	}
+	index, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/proof/"), 10, 64)
+	if err != nil || index >= 1<<plasma.TreeHeight {
+		writeError(w, http.StatusBadRequest, errors.New("Invalid index"))
+		return
+	}
+	var root []byte
+	if query := r.URL.Query().Get("root"); query != "" {
+		if root, err = csmt.DecodeHex(query); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	response, err := s.proof(index, root)
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, response)
+	case errUnknownRoot:
+		writeError(w, http.StatusNotFound, err)
+	case errStaleRoot:
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusServiceUnavailable, err)
+	}
+}
+
+func (s *Service) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("Method is not allowed"))
+		return
+	}
+	var request UpdateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	response, err := s.Update(request.Index, request.Proof)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,167 @@
+package proofservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+	"github.com/matterinc/PlasmaCompact/plasma"
+)
+
+// testChain runs an operator in process and feeds its blocks to the service: a deposit
+// block with three deposits and a block spending the first one.
+func testChain(t *testing.T, s *Service) (*plasma.BlockProducer, []*plasma.ProducedBlock) {
+	owner, err := plasma.NewEd25519Signer(bytes.Repeat([]byte{0x01}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := plasma.NewBlockProducer(csmt.NewCSMT(plasma.TreeHeight, true), owner)
+	feed := plasma.NewMemoryDepositFeed()
+	for _, amount := range []int64{10, 20, 30} {
+		feed.Deposit(owner.PubKey(), plasma.NativeToken, big.NewInt(amount))
+	}
+	deposits, _ := feed.Deposits(0, -1)
+	first, err := producer.ProduceDeposits(nil, plasma.NewDepositLedger(0), deposits, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	position := csmt.UTXOIndex(1, 0, 0)
+	spent := deposits[0].Output()
+	tx := &plasma.Transaction{
+		Inputs:  []plasma.Input{{Position: position, Spent: &spent, Proof: producer.Tree().Prove(position)}},
+		Outputs: []plasma.Output{spent},
+	}
+	if err := tx.SignInput(0, owner); err != nil {
+		t.Fatal(err)
+	}
+	second, err := producer.Produce(&first.Block.Header, []*plasma.Transaction{tx}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, produced := range []*plasma.ProducedBlock{first, second} {
+		if err := s.ApplyBlock(produced.Block, produced.AuditData); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return producer, []*plasma.ProducedBlock{first, second}
+}
+
+func decodeResponse(t *testing.T, r *http.Response) *ProofResponse {
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected status %v", r.Status)
+	}
+	response := new(ProofResponse)
+	if err := json.NewDecoder(r.Body).Decode(response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func checkResponse(t *testing.T, response *ProofResponse, producer *plasma.BlockProducer) {
+	if response.Block != 2 || response.Root != csmt.EncodeHex(producer.Tree().RootHash()) {
+		t.Fatal("Response is not against the latest block")
+	}
+	value, _ := csmt.DecodeHex(response.Value)
+	if err := response.Proof.VefiryPath(plasma.TreeHeight, response.Index, value, producer.Tree().RootHash()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProofService(t *testing.T) {
+	s := New(8)
+	producer, blocks := testChain(t, s)
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	index := csmt.UTXOIndex(1, 2, 0)
+	r, err := http.Get(fmt.Sprintf("%v/proof/%v?root=%v", server.URL, index, csmt.EncodeHex(blocks[1].Block.Header.SMTRoot)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResponse(t, decodeResponse(t, r), producer)
+
+	r, err = http.Get(fmt.Sprintf("%v/proof/%v?root=%v", server.URL, index, csmt.EncodeHex(blocks[0].Block.Header.SMTRoot)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusConflict {
+		t.Fatal("Older root was not reported")
+	}
+	r, err = http.Get(server.URL + "/proof/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusBadRequest {
+		t.Fatal("Invalid index was accepted")
+	}
+}
+
+func TestProofServiceUpdate(t *testing.T) {
+	s := New(8)
+	producer, blocks := testChain(t, s)
+	// the tree as it was after the deposit block, clients hold proofs against it
+	old := csmt.NewCSMT(plasma.TreeHeight, true)
+	deposits := blocks[0].Block.Transactions
+	var toInsert csmt.InsertionIndexes
+	for i := range deposits {
+		value, _ := deposits[i].Outputs[0].Encode()
+		toInsert = append(toInsert, csmt.InsertedIndex{Index: csmt.UTXOIndex(1, uint64(i), 0), Value: value})
+	}
+	old.ApplyInserts(toInsert)
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	index := csmt.UTXOIndex(1, 1, 0)
+	body, _ := json.Marshal(UpdateRequest{index, old.Prove(index)})
+	r, err := http.Post(server.URL+"/proof/update", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResponse(t, decodeResponse(t, r), producer)
+
+	spent := csmt.UTXOIndex(1, 0, 0)
+	body, _ = json.Marshal(UpdateRequest{spent, old.Prove(spent)})
+	r, err = http.Post(server.URL+"/proof/update", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatal("Proof of a spent output was updated")
+	}
+}
+
+func TestProofServiceRejectsInvalidBlocks(t *testing.T) {
+	s := New(8)
+	_, blocks := testChain(t, s)
+	if err := s.ApplyBlock(blocks[1].Block, blocks[1].AuditData); err == nil {
+		t.Fatal("Block was applied twice")
+	}
+	root := s.tree.RootHash()
+	other := New(8)
+	if err := other.ApplyBlock(blocks[0].Block, blocks[0].AuditData); err != nil {
+		t.Fatal(err)
+	}
+	missing := *blocks[1].Block.Transactions[0]
+	missing.Inputs = append([]plasma.Input{}, missing.Inputs...)
+	missing.Inputs[0].Spent = nil
+	withoutSpent := &plasma.Block{Header: blocks[1].Block.Header, Transactions: []*plasma.Transaction{&missing}}
+	if err := other.ApplyBlock(withoutSpent, blocks[1].AuditData); err == nil {
+		t.Fatal("Block with an input without the spent output was applied")
+	}
+	blocks[1].Block.Header.SMTRoot = root[:1]
+	if err := other.ApplyBlock(blocks[1].Block, blocks[1].AuditData); err == nil {
+		t.Fatal("Block with an invalid root was applied")
+	}
+	if bytes.Compare(other.tree.RootHash(), blocks[0].Block.Header.SMTRoot) != 0 {
+		t.Fatal("Invalid block changed the tree")
+	}
+}
@@ -0,0 +1,211 @@
+// Package rlp implements the recursive length prefix encoding of Ethereum. Decoding is
+// strict: only the canonical encoding of a value is accepted.
+package rlp
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+)
+
+// MaxDepth limits nesting of lists when decoding.
+const MaxDepth = 32
+
+// Item is a decoded value, either a byte string or a list of items.
+type Item struct {
+	List  bool
+	Bytes []byte
+	Items []Item
+}
+
+func encodeLength(length int, offset byte) []byte {
+	if length < 56 {
+		return []byte{offset + byte(length)}
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(length))
+	i := 0
+	for buf[i] == 0 {
+		i++
+	}
+	return append([]byte{offset + 55 + byte(8-i)}, buf[i:]...)
+}
+
+// EncodeBytes encodes a byte string.
+func EncodeBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return []byte{b[0]}
+	}
+	return append(encodeLength(len(b), 0x80), b...)
+}
+
+// EncodeUint encodes an integer as a big endian string without leading zeros.
+func EncodeUint(u uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, u)
+	i := 0
+	for i < 8 && buf[i] == 0 {
+		i++
+	}
+	return EncodeBytes(buf[i:])
+}
+
+// EncodeBigInt encodes a non negative integer like EncodeUint.
+func EncodeBigInt(b *big.Int) []byte {
+	return EncodeBytes(b.Bytes())
+}
+
+// EncodeList wraps already encoded items into a list.
+func EncodeList(items ...[]byte) []byte {
+	length := 0
+	for _, item := range items {
+		length += len(item)
+	}
+	encoded := encodeLength(length, 0xc0)
+	for _, item := range items {
+		encoded = append(encoded, item...)
+	}
+	return encoded
+}
+
+// Decode decodes a single item that has to take the whole input.
+func Decode(data []byte) (Item, error) {
+	item, used, err := decode(data, 0)
+	if err != nil {
+		return item, err
+	}
+	if used != len(data) {
+		return item, errors.New("Trailing bytes after RLP item")
+	}
+	return item, nil
+}
+
+// header parses the prefix of an item and returns whether it is a list, the offset of its
+// content and the content length.
+func header(data []byte) (bool, int, int, error) {
+	if len(data) == 0 {
+		return false, 0, 0, errors.New("RLP input is too short")
+	}
+	prefix := data[0]
+	switch {
+	case prefix < 0x80:
+		return false, 0, 1, nil
+	case prefix < 0xb8:
+		return false, 1, int(prefix - 0x80), nil
+	case prefix < 0xc0:
+		offset, length, err := longLength(data, int(prefix-0xb7))
+		return false, offset, length, err
+	case prefix < 0xf8:
+		return true, 1, int(prefix - 0xc0), nil
+	}
+	offset, length, err := longLength(data, int(prefix-0xf7))
+	return true, offset, length, err
+}
+
+func longLength(data []byte, size int) (int, int, error) {
+	if len(data) < 1+size {
+		return 0, 0, errors.New("RLP input is too short")
+	}
+	if data[1] == 0 {
+		return 0, 0, errors.New("Non canonical RLP length")
+	}
+	if size > 4 {
+		return 0, 0, errors.New("RLP length is too large")
+	}
+	length := 0
+	for _, b := range data[1 : 1+size] {
+		length = length<<8 | int(b)
+	}
+	if length < 56 {
+		return 0, 0, errors.New("Non canonical RLP length")
+	}
+	return 1 + size, length, nil
+}
+
+func decode(data []byte, depth int) (Item, int, error) {
+	var item Item
+	if depth > MaxDepth {
+		return item, 0, errors.New("RLP nesting is too deep")
+	}
+	list, offset, length, err := header(data)
+	if err != nil {
+		return item, 0, err
+	}
+	if len(data)-offset < length {
+		return item, 0, errors.New("RLP input is too short")
+	}
+	content := data[offset : offset+length]
+	if !list {
+		if offset == 1 && length == 1 && content[0] < 0x80 {
+			return item, 0, errors.New("Non canonical RLP single byte")
+		}
+		item.Bytes = append([]byte{}, content...)
+		return item, offset + length, nil
+	}
+	item.List = true
+	item.Items = []Item{}
+	for len(content) != 0 {
+		child, used, err := decode(content, depth+1)
+		if err != nil {
+			return item, 0, err
+		}
+		item.Items = append(item.Items, child)
+		content = content[used:]
+	}
+	return item, offset + length, nil
+}
+
+// Uint returns the integer value of a string item.
+func (i Item) Uint() (uint64, error) {
+	if i.List {
+		return 0, errors.New("Expected an RLP string")
+	}
+	if len(i.Bytes) > 8 {
+		return 0, errors.New("RLP integer is too large")
+	}
+	if len(i.Bytes) != 0 && i.Bytes[0] == 0 {
+		return 0, errors.New("Non canonical RLP integer")
+	}
+	var u uint64
+	for _, b := range i.Bytes {
+		u = u<<8 | uint64(b)
+	}
+	return u, nil
+}
+
+// BigInt returns the integer value of a string item of at most maxBytes bytes.
+func (i Item) BigInt(maxBytes int) (*big.Int, error) {
+	if i.List {
+		return nil, errors.New("Expected an RLP string")
+	}
+	if len(i.Bytes) > maxBytes {
+		return nil, errors.New("RLP integer is too large")
+	}
+	if len(i.Bytes) != 0 && i.Bytes[0] == 0 {
+		return nil, errors.New("Non canonical RLP integer")
+	}
+	return new(big.Int).SetBytes(i.Bytes), nil
+}
+
+// String returns the bytes of a string item, nil for an empty string.
+func (i Item) String() ([]byte, error) {
+	if i.List {
+		return nil, errors.New("Expected an RLP string")
+	}
+	if len(i.Bytes) == 0 {
+		return nil, nil
+	}
+	return i.Bytes, nil
+}
+
+// ListOf returns the items of a list item that must have exactly n items, any number if
+// n is negative.
+func (i Item) ListOf(n int) ([]Item, error) {
+	if !i.List {
+		return nil, errors.New("Expected an RLP list")
+	}
+	if n >= 0 && len(i.Items) != n {
+		return nil, errors.New("Unexpected number of RLP list items")
+	}
+	return i.Items, nil
+}
@@ -0,0 +1,70 @@
+package rlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestEncodeVectors(t *testing.T) {
+	vectors := []struct {
+		encoded []byte
+		hex     string
+	}{
+		{EncodeBytes([]byte("dog")), "83646f67"},
+		{EncodeList(EncodeBytes([]byte("cat")), EncodeBytes([]byte("dog"))), "c88363617483646f67"},
+		{EncodeBytes(nil), "80"},
+		{EncodeList(), "c0"},
+		{EncodeUint(0), "80"},
+		{EncodeUint(15), "0f"},
+		{EncodeUint(1024), "820400"},
+		{EncodeBigInt(big.NewInt(1024)), "820400"},
+		{EncodeList(EncodeList(), EncodeList(EncodeList()), EncodeList(EncodeList(), EncodeList(EncodeList()))), "c7c0c1c0c3c0c1c0"},
+		{EncodeBytes([]byte("Lorem ipsum dolor sit amet, consectetur adipisicing elit")),
+			"b8384c6f72656d20697073756d20646f6c6f722073697420616d65742c20636f6e7365637465747572206164697069736963696e6720656c6974"},
+	}
+	for i, v := range vectors {
+		if hex.EncodeToString(v.encoded) != v.hex {
+			t.Fatalf("Vector %v encoded as %x", i, v.encoded)
+		}
+		item, err := Decode(v.encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !item.List {
+			if !bytes.HasSuffix(v.encoded, item.Bytes) {
+				t.Fatalf("Vector %v did not survive a round trip", i)
+			}
+		}
+	}
+}
+
+func TestDecodeRejectsMalformed(t *testing.T) {
+	for _, malformed := range []string{
+		"",           // empty input
+		"8105",       // single byte below 0x80 in a string
+		"b800",       // long form for a short string
+		"b90000",     // leading zero in the length
+		"83646f",     // truncated string
+		"c883636174", // truncated list
+		"83646f6700", // trailing bytes
+		"c281",       // truncated item inside a list
+	} {
+		data, _ := hex.DecodeString(malformed)
+		if _, err := Decode(data); err == nil {
+			t.Fatalf("Malformed input %v was decoded", malformed)
+		}
+	}
+	nested := EncodeList()
+	for i := 0; i <= MaxDepth+1; i++ {
+		nested = EncodeList(nested)
+	}
+	if _, err := Decode(nested); err == nil {
+		t.Fatal("Too deep nesting was decoded")
+	}
+	item, _ := Decode([]byte{0x82, 0x00, 0x01})
+	if _, err := item.Uint(); err == nil {
+		t.Fatal("Integer with a leading zero was decoded")
+	}
+}
@@ -0,0 +1,106 @@
+package rpc
+
+import (
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+	"github.com/matterinc/PlasmaCompact/plasma"
+)
+
+// JSON forms of the chain objects. Byte fields are 0x prefixed hex, empty for null, and
+// amounts are decimal strings as they do not fit into JSON numbers.
+
+type Header struct {
+	Number          uint64 `json:"number"`
+	Hash            string `json:"hash"`
+	PrevHash        string `json:"prevHash"`
+	TxRoot          string `json:"txRoot"`
+	SMTRoot         string `json:"smtRoot"`
+	AuditCommitment string `json:"auditCommitment"`
+	Timestamp       uint64 `json:"timestamp"`
+	Deposits        uint64 `json:"deposits"`
+	DepositNonce    uint64 `json:"depositNonce"`
+	Signature       string `json:"signature"`
+}
+
+type Output struct {
+	Owner  string `json:"owner"`
+	Token  string `json:"token"`
+	Amount string `json:"amount"`
+}
+
+type Input struct {
+	Position  uint64          `json:"position"`
+	Spent     Output          `json:"spent"`
+	Proof     csmt.AuditNodes `json:"proof"`
+	Signature string          `json:"signature"`
+}
+
+type Transaction struct {
+	Hash    string   `json:"hash"` // signing hash, stays the same when proofs are updated
+	Inputs  []Input  `json:"inputs"`
+	Outputs []Output `json:"outputs"`
+}
+
+type Block struct {
+	Header       Header        `json:"header"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+// Proof is a proof of a leaf against the latest root.
+type Proof struct {
+	Index uint64          `json:"index"`
+	Block uint64          `json:"block"`
+	Root  string          `json:"root"`
+	Value string          `json:"value"` // leaf value, empty for an empty leaf
+	Proof csmt.AuditNodes `json:"proof"`
+}
+
+// UTXO is an unspent output with the block that created it.
+type UTXO struct {
+	Index  uint64 `json:"index"`
+	Block  uint64 `json:"block"`
+	Output Output `json:"output"`
+}
+
+// Root is the latest root of the tree.
+type Root struct {
+	Block uint64 `json:"block"`
+	Root  string `json:"root"`
+}
+
+// AuditData is the audit set of a block in the canonical order.
+type AuditData struct {
+	Block      uint64          `json:"block"`
+	Commitment string          `json:"commitment"`
+	Audit      csmt.AuditNodes `json:"audit"`
+}
+
+func newHeader(h *plasma.BlockHeader) Header {
+	hash, _ := h.Hash()
+	return Header{h.Number, csmt.EncodeHex(hash), csmt.EncodeHex(h.PrevHash), csmt.EncodeHex(h.TxRoot), csmt.EncodeHex(h.SMTRoot),
+		csmt.EncodeHex(h.AuditCommitment), h.Timestamp, h.Deposits, h.DepositNonce, csmt.EncodeHex(h.Signature)}
+}
+
+func newOutput(o *plasma.Output) Output {
+	return Output{csmt.EncodeHex(o.PubKey), csmt.EncodeHex(o.Metadata), o.Amount.String()}
+}
+
+func newTransaction(tx *plasma.Transaction) Transaction {
+	hash, _ := tx.SigningHash()
+	t := Transaction{Hash: csmt.EncodeHex(hash), Inputs: make([]Input, len(tx.Inputs)), Outputs: make([]Output, len(tx.Outputs))}
+	for i := range tx.Inputs {
+		in := &tx.Inputs[i]
+		t.Inputs[i] = Input{in.Position, newOutput(in.Spent), in.Proof, csmt.EncodeHex(in.Signature)}
+	}
+	for i := range tx.Outputs {
+		t.Outputs[i] = newOutput(&tx.Outputs[i])
+	}
+	return t
+}
+
+func newBlock(b *plasma.Block) Block {
+	block := Block{Header: newHeader(&b.Header), Transactions: make([]Transaction, len(b.Transactions))}
+	for i, tx := range b.Transactions {
+		block.Transactions[i] = newTransaction(tx)
+	}
+	return block
+}
@@ -0,0 +1,343 @@
+// Package rpc is the JSON-RPC 2.0 interface of the operator, served over HTTP POST:
+//
+//	plasma_sendTransaction [raw]      RLP encoded transaction as hex, returns its signing hash
+//	plasma_getBlock        [number]   block with its transactions
+//	plasma_getHeader       [number]   block header
+//	plasma_getProof        [index]    proof of a leaf against the latest root
+//	plasma_getAuditData    [number]   audit set of a block
+//	plasma_getUTXO         [index]    unspent output at the index, null if there is none
+//	plasma_latestRoot      []         latest block number and tree root
+//
+// Batches and notifications are supported. Byte fields are 0x prefixed hex.
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+	"github.com/matterinc/PlasmaCompact/plasma"
+)
+
+// Error codes of JSON-RPC 2.0, application errors use CodeServerError.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	CodeServerError    = -32000
+)
+
+// MaxRequestSize limits the body of a request.
+const MaxRequestSize = 1 << 22
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("JSON-RPC error %v: %v", e.Code, e.Message)
+}
+
+func invalidParams(err error) *Error {
+	return &Error{CodeInvalidParams, err.Error()}
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type method func(s *Server, params json.RawMessage) (interface{}, error)
+
+var methods = map[string]method{
+	"plasma_sendTransaction": (*Server).sendTransaction,
+	"plasma_getBlock":        (*Server).getBlock,
+	"plasma_getHeader":       (*Server).getHeader,
+	"plasma_getProof":        (*Server).getProof,
+	"plasma_getAuditData":    (*Server).getAuditData,
+	"plasma_getUTXO":         (*Server).getUTXO,
+	"plasma_latestRoot":      (*Server).latestRoot,
+}
+
+// Server runs the operator: it admits transactions into the pool, produces blocks from it
+// and answers queries about the chain.
+type Server struct {
+	mu       sync.RWMutex
+	producer *plasma.BlockProducer
+	pool     *plasma.Mempool
+	store    BlockStore
+}
+
+// NewServer creates a server on top of a producer and a pool that are in sync with the last
+// stored block.
+func NewServer(producer *plasma.BlockProducer, pool *plasma.Mempool, store BlockStore) *Server {
+	return &Server{producer: producer, pool: pool, store: store}
+}
+
+func (s *Server) lastHeader() (*plasma.BlockHeader, error) {
+	if s.store.Latest() == 0 {
+		return nil, nil
+	}
+	last, err := s.store.Block(s.store.Latest())
+	if err != nil {
+		return nil, err
+	}
+	return &last.Block.Header, nil
+}
+
+// ProduceBlock turns up to max pending transactions, all of them if max is negative, into
+// the next block and stores it.
+func (s *Server) ProduceBlock(max int, timestamp uint64) (*plasma.ProducedBlock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, err := s.lastHeader()
+	if err != nil {
+		return nil, err
+	}
+	produced, err := s.producer.Produce(prev, s.pool.Select(max), timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Add(produced); err != nil {
+		return nil, err
+	}
+	s.pool.BlockProduced(produced)
+	return produced, nil
+}
+
+// ServeHTTP answers a single request or a batch.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(http.MaxBytesReader(w, r.Body, MaxRequestSize)); err != nil {
+		http.Error(w, "Request is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	data := bytes.TrimSpace(body.Bytes())
+	var reply interface{}
+	if len(data) != 0 && data[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(data, &batch); err != nil {
+			reply = errorResponse(nil, &Error{CodeParseError, err.Error()})
+		} else if len(batch) == 0 {
+			reply = errorResponse(nil, &Error{CodeInvalidRequest, "Empty batch"})
+		} else {
+			responses := make([]*response, 0, len(batch))
+			for _, item := range batch {
+				if res := s.handle(item); res != nil {
+					responses = append(responses, res)
+				}
+			}
+			if len(responses) != 0 {
+				reply = responses
+			}
+		}
+	} else if res := s.handle(data); res != nil {
+		reply = res
+	}
+	if reply == nil {
+		// only notifications
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(reply)
+}
+
+func errorResponse(id json.RawMessage, err *Error) *response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &response{JSONRPC: "2.0", Error: err, ID: id}
+}
+
+// handle answers a single request, nil for a notification.
+func (s *Server) handle(data []byte) *response {
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		if _, syntax := err.(*json.SyntaxError); syntax {
+			return errorResponse(nil, &Error{CodeParseError, err.Error()})
+		}
+		return errorResponse(nil, &Error{CodeInvalidRequest, err.Error()})
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, &Error{CodeInvalidRequest, "Invalid JSON-RPC 2.0 request"})
+	}
+	m, exists := methods[req.Method]
+	var result interface{}
+	var err error
+	if exists {
+		result, err = m(s, req.Params)
+	} else {
+		err = &Error{CodeMethodNotFound, fmt.Sprintf("Method %v is not found", req.Method)}
+	}
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = &Error{CodeServerError, err.Error()}
+		}
+		return errorResponse(req.ID, rpcErr)
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, &Error{CodeInternalError, err.Error()})
+	}
+	return &response{JSONRPC: "2.0", Result: encoded, ID: req.ID}
+}
+
+// parseParams decodes positional parameters, all of them are required.
+func parseParams(params json.RawMessage, values ...interface{}) error {
+	var items []json.RawMessage
+	if len(params) != 0 {
+		if err := json.Unmarshal(params, &items); err != nil {
+			return invalidParams(errors.New("Parameters must be an array"))
+		}
+	}
+	if len(items) != len(values) {
+		return invalidParams(fmt.Errorf("Expected %v parameters, got %v", len(values), len(items)))
+	}
+	for i := range items {
+		if err := json.Unmarshal(items[i], values[i]); err != nil {
+			return invalidParams(fmt.Errorf("Parameter %v: %v", i, err))
+		}
+	}
+	return nil
+}
+
+func (s *Server) block(params json.RawMessage) (*plasma.ProducedBlock, error) {
+	var number uint64
+	if err := parseParams(params, &number); err != nil {
+		return nil, err
+	}
+	return s.store.Block(number)
+}
+
+func leafIndex(params json.RawMessage) (uint64, error) {
+	var index uint64
+	if err := parseParams(params, &index); err != nil {
+		return 0, err
+	}
+	if index >= 1<<plasma.TreeHeight {
+		return 0, invalidParams(errors.New("Index is out of range"))
+	}
+	return index, nil
+}
+
+func (s *Server) sendTransaction(params json.RawMessage) (interface{}, error) {
+	var raw string
+	if err := parseParams(params, &raw); err != nil {
+		return nil, err
+	}
+	encoded, err := csmt.DecodeHex(raw)
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+	tx, err := plasma.DecodeTransactionRLP(encoded)
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.pool.Add(tx); err != nil {
+		return nil, err
+	}
+	hash, _ := tx.SigningHash()
+	return csmt.EncodeHex(hash), nil
+}
+
+func (s *Server) getBlock(params json.RawMessage) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	produced, err := s.block(params)
+	if err != nil {
+		return nil, err
+	}
+	return newBlock(produced.Block), nil
+}
+
+func (s *Server) getHeader(params json.RawMessage) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	produced, err := s.block(params)
+	if err != nil {
+		return nil, err
+	}
+	return newHeader(&produced.Block.Header), nil
+}
+
+func (s *Server) getAuditData(params json.RawMessage) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	produced, err := s.block(params)
+	if err != nil {
+		return nil, err
+	}
+	header := &produced.Block.Header
+	audit := produced.Audit
+	if audit == nil {
+		audit = csmt.AuditNodes{}
+	}
+	return &AuditData{header.Number, csmt.EncodeHex(header.AuditCommitment), audit}, nil
+}
+
+func (s *Server) getProof(params json.RawMessage) (interface{}, error) {
+	index, err := leafIndex(params)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tree := s.producer.Tree()
+	return &Proof{index, s.store.Latest(), csmt.EncodeHex(tree.RootHash()), csmt.EncodeHex(tree.Leaf(index)), tree.Prove(index)}, nil
+}
+
+func (s *Server) getUTXO(params json.RawMessage) (interface{}, error) {
+	index, err := leafIndex(params)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value := s.producer.Tree().Leaf(index)
+	if value == nil {
+		return nil, nil
+	}
+	output, err := plasma.DecodeOutput(value)
+	if err != nil {
+		return nil, err
+	}
+	block, _, _ := csmt.SplitUTXOIndex(index)
+	return &UTXO{index, block, newOutput(output)}, nil
+}
+
+func (s *Server) latestRoot(params json.RawMessage) (interface{}, error) {
+	if err := parseParams(params); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &Root{s.store.Latest(), csmt.EncodeHex(s.producer.Tree().RootHash())}, nil
+}
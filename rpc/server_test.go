@@ -0,0 +1,205 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+	"github.com/matterinc/PlasmaCompact/plasma"
+)
+
+func testSigner(t *testing.T, seed byte) plasma.Signer {
+	signer, err := plasma.NewEd25519Signer(bytes.Repeat([]byte{seed}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// testServer runs an operator whose first block deposits 100 for the signer with seed 0x01.
+func testServer(t *testing.T) (*Server, *httptest.Server) {
+	producer := plasma.NewBlockProducer(csmt.NewCSMT(plasma.TreeHeight, true), testSigner(t, 0xff))
+	feed := plasma.NewMemoryDepositFeed()
+	feed.Deposit(testSigner(t, 0x01).PubKey(), plasma.NativeToken, big.NewInt(100))
+	deposits, _ := feed.Deposits(0, -1)
+	produced, err := producer.ProduceDeposits(nil, plasma.NewDepositLedger(0), deposits, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryBlockStore()
+	if err := store.Add(produced); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(producer, plasma.NewMempool(producer.Tree(), 4), store)
+	return s, httptest.NewServer(s)
+}
+
+func post(t *testing.T, url, body string) *http.Response {
+	r, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func call(t *testing.T, url, method string, result interface{}, params ...interface{}) *Error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	r := post(t, url, string(body))
+	defer r.Body.Close()
+	var res struct {
+		Result json.RawMessage `json:"result"`
+		Error  *Error          `json:"error"`
+		ID     int             `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.ID != 1 {
+		t.Fatal("Response id does not match")
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if err := json.Unmarshal(res.Result, result); err != nil {
+		t.Fatal(err)
+	}
+	return nil
+}
+
+func TestServerEndToEnd(t *testing.T) {
+	s, server := testServer(t)
+	defer server.Close()
+
+	var root Root
+	if err := call(t, server.URL, "plasma_latestRoot", &root); err != nil {
+		t.Fatal(err)
+	}
+	if root.Block != 1 || root.Root != csmt.EncodeHex(s.producer.Tree().RootHash()) {
+		t.Fatal("Invalid latest root")
+	}
+	index := csmt.UTXOIndex(1, 0, 0)
+	var utxo *UTXO
+	if err := call(t, server.URL, "plasma_getUTXO", &utxo, index); err != nil {
+		t.Fatal(err)
+	}
+	owner := testSigner(t, 0x01)
+	if utxo == nil || utxo.Block != 1 || utxo.Output.Owner != csmt.EncodeHex(owner.PubKey()) || utxo.Output.Amount != "100" {
+		t.Fatal("Invalid deposit output")
+	}
+	var proof Proof
+	if err := call(t, server.URL, "plasma_getProof", &proof, index); err != nil {
+		t.Fatal(err)
+	}
+	value, _ := csmt.DecodeHex(proof.Value)
+	rootHash, _ := csmt.DecodeHex(proof.Root)
+	if err := proof.Proof.VefiryPath(plasma.TreeHeight, index, value, rootHash); err != nil {
+		t.Fatal(err)
+	}
+
+	spent, _ := plasma.DecodeOutput(value)
+	tx := &plasma.Transaction{
+		Inputs:  []plasma.Input{{Position: index, Spent: spent, Proof: proof.Proof}},
+		Outputs: []plasma.Output{{PubKey: testSigner(t, 0x02).PubKey(), Metadata: plasma.NativeToken[:], Amount: big.NewInt(99)}},
+	}
+	if err := tx.SignInput(0, owner); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := tx.EncodeRLP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hash string
+	if err := call(t, server.URL, "plasma_sendTransaction", &hash, csmt.EncodeHex(encoded)); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(t, server.URL, "plasma_sendTransaction", &hash, csmt.EncodeHex(encoded)); err == nil || err.Code != CodeServerError {
+		t.Fatal("Transaction was accepted twice")
+	}
+	if _, err := s.ProduceBlock(-1, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	var block Block
+	if err := call(t, server.URL, "plasma_getBlock", &block, 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Transactions) != 1 || block.Transactions[0].Hash != hash || block.Transactions[0].Outputs[0].Amount != "99" {
+		t.Fatal("Block does not include the transaction")
+	}
+	var header Header
+	if err := call(t, server.URL, "plasma_getHeader", &header, 2); err != nil {
+		t.Fatal(err)
+	}
+	if header != block.Header || header.Timestamp != 10 {
+		t.Fatal("Invalid header")
+	}
+	var audit AuditData
+	if err := call(t, server.URL, "plasma_getAuditData", &audit, 2); err != nil {
+		t.Fatal(err)
+	}
+	commitment, _ := audit.Audit.Commitment()
+	if csmt.EncodeHex(commitment) != header.AuditCommitment || audit.Commitment != header.AuditCommitment {
+		t.Fatal("Audit data does not match the header")
+	}
+	if err := call(t, server.URL, "plasma_getUTXO", &utxo, index); err != nil || utxo != nil {
+		t.Fatal("Spent output was returned")
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	_, server := testServer(t)
+	defer server.Close()
+	var result json.RawMessage
+	if err := call(t, server.URL, "plasma_unknown", &result); err == nil || err.Code != CodeMethodNotFound {
+		t.Fatal("Unknown method was not reported")
+	}
+	if err := call(t, server.URL, "plasma_getBlock", &result); err == nil || err.Code != CodeInvalidParams {
+		t.Fatal("Missing parameter was not reported")
+	}
+	if err := call(t, server.URL, "plasma_getProof", &result, uint64(1)<<plasma.TreeHeight); err == nil || err.Code != CodeInvalidParams {
+		t.Fatal("Index out of range was not reported")
+	}
+	if err := call(t, server.URL, "plasma_getBlock", &result, 5); err == nil || err.Code != CodeServerError {
+		t.Fatal("Unknown block was not reported")
+	}
+	if err := call(t, server.URL, "plasma_sendTransaction", &result, "0x01"); err == nil || err.Code != CodeInvalidParams {
+		t.Fatal("Malformed transaction was not reported")
+	}
+
+	var res response
+	r := post(t, server.URL, `{"jsonrpc": "2.0", "method": `)
+	json.NewDecoder(r.Body).Decode(&res)
+	r.Body.Close()
+	if res.Error == nil || res.Error.Code != CodeParseError {
+		t.Fatal("Parse error was not reported")
+	}
+	r = post(t, server.URL, `{"jsonrpc": "1.0", "method": "plasma_latestRoot", "id": 1}`)
+	json.NewDecoder(r.Body).Decode(&res)
+	r.Body.Close()
+	if res.Error == nil || res.Error.Code != CodeInvalidRequest {
+		t.Fatal("Invalid request was not reported")
+	}
+
+	var batch []response
+	r = post(t, server.URL, `[{"jsonrpc": "2.0", "method": "plasma_latestRoot", "id": 1},
+		{"jsonrpc": "2.0", "method": "plasma_latestRoot"},
+		{"jsonrpc": "2.0", "method": "plasma_getHeader", "params": [1], "id": 2}]`)
+	json.NewDecoder(r.Body).Decode(&batch)
+	r.Body.Close()
+	if len(batch) != 2 || batch[0].Error != nil || batch[1].Error != nil || string(batch[1].ID) != "2" {
+		t.Fatal("Invalid batch response")
+	}
+	r = post(t, server.URL, `{"jsonrpc": "2.0", "method": "plasma_latestRoot"}`)
+	r.Body.Close()
+	if r.StatusCode != http.StatusNoContent {
+		t.Fatal("Notification was answered")
+	}
+}
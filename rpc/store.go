@@ -0,0 +1,45 @@
+package rpc
+
+import (
+	"fmt"
+
+	"github.com/matterinc/PlasmaCompact/plasma"
+)
+
+// BlockStore keeps the produced blocks with their audit data.
+type BlockStore interface {
+	// Latest returns the number of the last block, 0 if there are none.
+	Latest() uint64
+	// Block returns a stored block.
+	Block(number uint64) (*plasma.ProducedBlock, error)
+	// Add stores the next block.
+	Add(produced *plasma.ProducedBlock) error
+}
+
+// MemoryBlockStore keeps blocks in memory. It is not safe for concurrent use on its own.
+type MemoryBlockStore struct {
+	blocks []*plasma.ProducedBlock
+}
+
+func NewMemoryBlockStore() *MemoryBlockStore {
+	return new(MemoryBlockStore)
+}
+
+func (s *MemoryBlockStore) Latest() uint64 {
+	return uint64(len(s.blocks))
+}
+
+func (s *MemoryBlockStore) Block(number uint64) (*plasma.ProducedBlock, error) {
+	if number == 0 || number > uint64(len(s.blocks)) {
+		return nil, fmt.Errorf("Block %v is not known", number)
+	}
+	return s.blocks[number-1], nil
+}
+
+func (s *MemoryBlockStore) Add(produced *plasma.ProducedBlock) error {
+	if produced.Block.Header.Number != uint64(len(s.blocks))+1 {
+		return fmt.Errorf("Expected block %v, got %v", len(s.blocks)+1, produced.Block.Header.Number)
+	}
+	s.blocks = append(s.blocks, produced)
+	return nil
+}
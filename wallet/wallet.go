@@ -0,0 +1,223 @@
+// Package wallet is the client side of Plasma Compact: it keeps the owner's unspent outputs
+// with proofs against the latest block and builds signed spends.
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+	"github.com/matterinc/PlasmaCompact/plasma"
+)
+
+// UTXO is an owned output with its proof.
+type UTXO struct {
+	Position uint64
+	Output   plasma.Output
+	Proof    csmt.AuditNodes
+	// Stale is set when the proof could not be updated, it stays against an older root
+	// until a fresh one is set with SetProof.
+	Stale bool
+}
+
+// Update tells what a block changed for the wallet.
+type Update struct {
+	Received []*UTXO
+	Spent    []uint64
+	Stale    []uint64 // outputs whose proofs could not be updated by this block
+}
+
+// Wallet tracks the outputs of a single key.
+type Wallet struct {
+	signer   plasma.Signer
+	operator []byte // public key blocks have to be signed with
+	last     *plasma.BlockHeader
+	utxos    map[uint64]*UTXO
+}
+
+// New creates a wallet following the operator's chain after the given header, nil for a
+// new chain.
+func New(signer plasma.Signer, operator []byte, last *plasma.BlockHeader) *Wallet {
+	return &Wallet{signer, operator, last, make(map[uint64]*UTXO)}
+}
+
+// Last returns the header of the last processed block.
+func (w *Wallet) Last() *plasma.BlockHeader {
+	return w.last
+}
+
+// UTXOs returns the owned outputs ordered by position.
+func (w *Wallet) UTXOs() []*UTXO {
+	utxos := make([]*UTXO, 0, len(w.utxos))
+	for _, u := range w.utxos {
+		utxos = append(utxos, u)
+	}
+	sort.Slice(utxos, func(i, j int) bool { return utxos[i].Position < utxos[j].Position })
+	return utxos
+}
+
+// Balance sums the owned outputs of the token.
+func (w *Wallet) Balance(token plasma.Token) *big.Int {
+	balance := new(big.Int)
+	for _, u := range w.utxos {
+		if bytes.Compare(u.Output.Metadata, token[:]) == 0 {
+			balance.Add(balance, u.Output.Amount)
+		}
+	}
+	return balance
+}
+
+// SetProof replaces the proof of an owned output, e.g. with one fetched from the operator.
+func (w *Wallet) SetProof(position uint64, proof csmt.AuditNodes) error {
+	u, exists := w.utxos[position]
+	if !exists {
+		return errors.New("Output is not owned")
+	}
+	if w.last == nil {
+		return errors.New("No block was processed")
+	}
+	value, _ := u.Output.Encode()
+	if err := proof.VefiryPath(plasma.TreeHeight, position, value, w.last.SMTRoot); err != nil {
+		return err
+	}
+	u.Proof, u.Stale = proof, false
+	return nil
+}
+
+func (w *Wallet) checkHeader(header *plasma.BlockHeader) error {
+	if w.last == nil {
+		if header.Number != 1 || len(header.PrevHash) != 0 {
+			return errors.New("Block is not the first one")
+		}
+		return nil
+	}
+	prevHash, err := w.last.Hash()
+	if err != nil {
+		return err
+	}
+	if header.Number != w.last.Number+1 || bytes.Compare(header.PrevHash, prevHash) != 0 {
+		return errors.New("Block does not follow the last processed one")
+	}
+	return nil
+}
+
+// ProcessBlock consumes the next block and its audit data: spent outputs are forgotten,
+// received ones are added with proofs taken from the audit data, and the proofs of all
+// other outputs are updated to the new root. Nothing is applied unless the header is
+// signed by the operator and commits to the block transactions.
+func (w *Wallet) ProcessBlock(block *plasma.Block, auditData []byte) (*Update, error) {
+	header := &block.Header
+	if err := w.checkHeader(header); err != nil {
+		return nil, err
+	}
+	if err := header.VerifySignature(w.operator); err != nil {
+		return nil, err
+	}
+	if err := block.CheckTransactionRoot(); err != nil {
+		return nil, err
+	}
+	audit, err := csmt.DecodeAuditNodes(auditData)
+	if err != nil {
+		return nil, err
+	}
+	commitment, err := audit.Commitment()
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Compare(commitment, header.AuditCommitment) != 0 {
+		return nil, errors.New("Audit data does not match the header commitment")
+	}
+
+	update := new(Update)
+	for _, tx := range block.Transactions {
+		for i := range tx.Inputs {
+			if _, owned := w.utxos[tx.Inputs[i].Position]; owned {
+				delete(w.utxos, tx.Inputs[i].Position)
+				update.Spent = append(update.Spent, tx.Inputs[i].Position)
+			}
+		}
+	}
+	for _, u := range w.UTXOs() {
+		if u.Stale {
+			update.Stale = append(update.Stale, u.Position)
+			continue
+		}
+		value, _ := u.Output.Encode()
+		proof, err := u.Proof.UpdateProofImproved(u.Position, audit)
+		if err == nil {
+			err = proof.VefiryPath(plasma.TreeHeight, u.Position, value, header.SMTRoot)
+		}
+		if err != nil {
+			u.Stale = true
+			update.Stale = append(update.Stale, u.Position)
+			continue
+		}
+		u.Proof = proof
+	}
+	owner := w.signer.PubKey()
+	for i, tx := range block.Transactions {
+		for j := range tx.Outputs {
+			out := tx.Outputs[j]
+			if bytes.Compare(out.PubKey, owner) != 0 {
+				continue
+			}
+			u := &UTXO{Position: csmt.UTXOIndex(header.Number, uint64(i), uint64(j)), Output: out}
+			value, err := out.Encode()
+			if err != nil {
+				return nil, err
+			}
+			u.Proof = audit.FilterPath(plasma.TreeHeight, u.Position)
+			if u.Proof.VefiryPath(plasma.TreeHeight, u.Position, value, header.SMTRoot) != nil {
+				u.Stale = true
+				update.Stale = append(update.Stale, u.Position)
+			}
+			w.utxos[u.Position] = u
+			update.Received = append(update.Received, u)
+		}
+	}
+	w.last = header
+	return update, nil
+}
+
+// BuildSpend builds and signs a transaction paying amount of the token to the recipient
+// and leaving fee to the operator. Oldest outputs are spent first, the change goes back to
+// the wallet. Outputs with stale proofs are not used.
+func (w *Wallet) BuildSpend(recipient []byte, token plasma.Token, amount, fee *big.Int) (*plasma.Transaction, error) {
+	if amount.Sign() <= 0 || fee.Sign() < 0 {
+		return nil, errors.New("Invalid amount")
+	}
+	needed := new(big.Int).Add(amount, fee)
+	collected := new(big.Int)
+	tx := new(plasma.Transaction)
+	for _, u := range w.UTXOs() {
+		if collected.Cmp(needed) >= 0 {
+			break
+		}
+		if u.Stale || bytes.Compare(u.Output.Metadata, token[:]) != 0 {
+			continue
+		}
+		if len(tx.Inputs) == plasma.MaxInputs {
+			return nil, errors.New("Amount needs too many inputs")
+		}
+		spent := u.Output
+		tx.Inputs = append(tx.Inputs, plasma.Input{Position: u.Position, Spent: &spent, Proof: u.Proof})
+		collected.Add(collected, u.Output.Amount)
+	}
+	if collected.Cmp(needed) < 0 {
+		return nil, fmt.Errorf("Insufficient balance: %v available, %v needed", collected, needed)
+	}
+	metadata := append([]byte{}, token[:]...)
+	tx.Outputs = append(tx.Outputs, plasma.Output{PubKey: recipient, Metadata: metadata, Amount: new(big.Int).Set(amount)})
+	if change := collected.Sub(collected, needed); change.Sign() > 0 {
+		tx.Outputs = append(tx.Outputs, plasma.Output{PubKey: w.signer.PubKey(), Metadata: metadata, Amount: change})
+	}
+	for i := range tx.Inputs {
+		if err := tx.SignInput(i, w.signer); err != nil {
+			return nil, err
+		}
+	}
+	return tx, nil
+}
@@ -0,0 +1,140 @@
+package wallet
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	csmt "github.com/matterinc/PlasmaCompact/compactPlasmaSMT"
+	"github.com/matterinc/PlasmaCompact/plasma"
+)
+
+func testSigner(t *testing.T, seed byte) plasma.Signer {
+	signer, err := plasma.NewEd25519Signer(bytes.Repeat([]byte{seed}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// testChain mints deposits of 70 and 30 for the wallet and 50 for somebody else.
+func testChain(t *testing.T, w *Wallet) (*plasma.BlockProducer, *plasma.ProducedBlock) {
+	producer := plasma.NewBlockProducer(csmt.NewCSMT(plasma.TreeHeight, true), testSigner(t, 0xff))
+	feed := plasma.NewMemoryDepositFeed()
+	feed.Deposit(w.signer.PubKey(), plasma.NativeToken, big.NewInt(70))
+	feed.Deposit(testSigner(t, 0x02).PubKey(), plasma.NativeToken, big.NewInt(50))
+	feed.Deposit(w.signer.PubKey(), plasma.NativeToken, big.NewInt(30))
+	deposits, _ := feed.Deposits(0, -1)
+	produced, err := producer.ProduceDeposits(nil, plasma.NewDepositLedger(0), deposits, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return producer, produced
+}
+
+func checkProofs(t *testing.T, w *Wallet) {
+	for _, u := range w.UTXOs() {
+		value, _ := u.Output.Encode()
+		if err := u.Proof.VefiryPath(plasma.TreeHeight, u.Position, value, w.Last().SMTRoot); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWalletFollowsChain(t *testing.T) {
+	w := New(testSigner(t, 0x01), testSigner(t, 0xff).PubKey(), nil)
+	producer, produced := testChain(t, w)
+	update, err := w.ProcessBlock(produced.Block, produced.AuditData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(update.Received) != 2 || w.Balance(plasma.NativeToken).Int64() != 100 {
+		t.Fatal("Deposits were not received")
+	}
+	checkProofs(t, w)
+
+	recipient := testSigner(t, 0x02).PubKey()
+	tx, err := w.BuildSpend(recipient, plasma.NativeToken, big.NewInt(60), big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.Inputs) != 1 || len(tx.Outputs) != 2 {
+		t.Fatal("Spend does not use the oldest output with change")
+	}
+	produced, err = producer.Produce(w.Last(), []*plasma.Transaction{tx}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update, err = w.ProcessBlock(produced.Block, produced.AuditData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(update.Spent) != 1 || len(update.Received) != 1 || len(update.Stale) != 0 {
+		t.Fatal("Spend and change were not detected")
+	}
+	if w.Balance(plasma.NativeToken).Int64() != 39 {
+		t.Fatal("Invalid balance after a spend")
+	}
+	checkProofs(t, w)
+
+	if _, err := w.BuildSpend(recipient, plasma.NativeToken, big.NewInt(40), big.NewInt(0)); err == nil {
+		t.Fatal("Spend above the balance was built")
+	}
+	if _, err := w.ProcessBlock(produced.Block, produced.AuditData); err == nil {
+		t.Fatal("Block was processed twice")
+	}
+}
+
+func TestWalletFlagsStaleProofs(t *testing.T) {
+	w := New(testSigner(t, 0x01), testSigner(t, 0xff).PubKey(), nil)
+	producer, produced := testChain(t, w)
+	if _, err := w.ProcessBlock(produced.Block, produced.AuditData); err != nil {
+		t.Fatal(err)
+	}
+	tx, _ := w.BuildSpend(testSigner(t, 0x02).PubKey(), plasma.NativeToken, big.NewInt(10), big.NewInt(0))
+	produced, err := producer.Produce(w.Last(), []*plasma.Transaction{tx}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken := w.UTXOs()[1]
+	broken.Proof = append(csmt.AuditNodes{}, broken.Proof...)
+	broken.Proof[len(broken.Proof)-1].Value = bytes.Repeat([]byte{0x01}, 32)
+	update, err := w.ProcessBlock(produced.Block, produced.AuditData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(update.Stale) != 1 || update.Stale[0] != broken.Position || !broken.Stale {
+		t.Fatal("Proof that could not be updated was not flagged")
+	}
+	if _, err := w.BuildSpend(testSigner(t, 0x02).PubKey(), plasma.NativeToken, big.NewInt(70), big.NewInt(0)); err == nil {
+		t.Fatal("Output with a stale proof was spent")
+	}
+	if err := w.SetProof(broken.Position, producer.Tree().Prove(broken.Position)); err != nil {
+		t.Fatal(err)
+	}
+	checkProofs(t, w)
+}
+
+func TestWalletChecksBlocks(t *testing.T) {
+	w := New(testSigner(t, 0x01), testSigner(t, 0xff).PubKey(), nil)
+	_, produced := testChain(t, w)
+	block := *produced.Block
+	block.Transactions = block.Transactions[1:]
+	if _, err := w.ProcessBlock(&block, produced.AuditData); err == nil {
+		t.Fatal("Block with an invalid tx root was processed")
+	}
+
+	block = *produced.Block
+	if err := block.Header.Sign(testSigner(t, 0x02)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.ProcessBlock(&block, produced.AuditData); err == nil {
+		t.Fatal("Block signed by somebody else was processed")
+	}
+	if w.Last() != nil || len(w.UTXOs()) != 0 {
+		t.Fatal("Rejected block was applied")
+	}
+	if _, err := w.ProcessBlock(produced.Block, produced.AuditData); err != nil {
+		t.Fatal(err)
+	}
+}